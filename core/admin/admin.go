@@ -0,0 +1,318 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server"
+	"github.com/lshaofan/cb-framework/server/web"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultAddr 默认只监听本机 需要远程访问时显式配置
+	DefaultAddr = "127.0.0.1:6060"
+	// TokenHeader 传递token的请求头 也可以使用 Authorization: Bearer <token>
+	// 不支持通过查询参数传递 避免token出现在访问日志和浏览器历史中
+	TokenHeader = "X-Admin-Token"
+)
+
+var (
+	// ErrTokenRequired 开启管理服务时必须配置token
+	ErrTokenRequired = errors.New("admin: 开启管理服务时必须配置token")
+)
+
+// Config 管理服务的配置 可以嵌入到 config.Config 的配置结构体中
+type Config struct {
+	// Enable 是否开启管理服务 默认关闭
+	Enable bool `yaml:"enable" json:"enable"`
+	// Addr 监听地址 默认为 127.0.0.1:6060
+	Addr string `yaml:"addr" json:"addr"`
+	// Token 访问管理接口需要的token
+	Token string `yaml:"token" json:"token"`
+}
+
+type Option func(*Admin)
+
+// WithEngines 设置需要列出路由的gin引擎
+func WithEngines(engines ...*gin.Engine) Option {
+	return func(a *Admin) {
+		a.engines = append(a.engines, engines...)
+	}
+}
+
+// WithLoggers 设置可以通过接口查看和修改级别的日志 默认为logrus的标准日志 参数为空时不做处理
+func WithLoggers(loggers ...*logrus.Logger) Option {
+	return func(a *Admin) {
+		if len(loggers) > 0 {
+			a.loggers = loggers
+		}
+	}
+}
+
+// Admin 管理和调试服务 实现 server.Service 在单独的端口提供 pprof 运行时状态 构建信息 日志级别 路由列表
+// 配置未开启时所有方法都不做任何处理 可以始终注册到 server.Group
+type Admin struct {
+	config  Config
+	engines []*gin.Engine
+	loggers []*logrus.Logger
+	started time.Time
+	http    *web.HTTPServer
+}
+
+func New(config Config, opts ...Option) *Admin {
+	if config.Addr == "" {
+		config.Addr = DefaultAddr
+	}
+	a := &Admin{
+		config:  config,
+		loggers: []*logrus.Logger{logrus.StandardLogger()},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Name 实现 server.Namer
+func (a *Admin) Name() string {
+	return "admin"
+}
+
+// Init 实现 server.Service
+func (a *Admin) Init(env server.Environment) error {
+	if !a.config.Enable {
+		return nil
+	}
+	if a.config.Token == "" {
+		return ErrTokenRequired
+	}
+	// cpu profile 和 trace 默认采集30秒 停止时不等待
+	a.http = web.NewHTTPServer(web.WithAddr(a.config.Addr), web.WithDrainTimeout(time.Second))
+	a.routes(a.http.Engine())
+	return a.http.Init(env)
+}
+
+// Start 实现 server.Service
+func (a *Admin) Start() error {
+	if a.http == nil {
+		return nil
+	}
+	a.started = time.Now()
+	return a.http.Start()
+}
+
+// Stop 实现 server.Service
+func (a *Admin) Stop() error {
+	return a.StopContext(context.Background())
+}
+
+// StopContext 实现 server.ContextStopper
+func (a *Admin) StopContext(ctx context.Context) error {
+	if a.http == nil {
+		return nil
+	}
+	return a.http.StopContext(ctx)
+}
+
+// Fatal 实现 server.Fataler
+func (a *Admin) Fatal() <-chan error {
+	if a.http == nil {
+		return nil
+	}
+	return a.http.Fatal()
+}
+
+func (a *Admin) routes(engine *gin.Engine) {
+	g := engine.Group("/debug", a.auth())
+	g.GET("/pprof/*name", pprofHandler)
+	g.POST("/pprof/*name", pprofHandler)
+	g.GET("/goroutines", goroutines)
+	g.GET("/stats", a.stats)
+	g.GET("/build", build)
+	g.GET("/log-level", a.logLevel)
+	g.PUT("/log-level", a.setLogLevel)
+	g.GET("/routes", a.listRoutes)
+}
+
+// auth 校验token
+func (a *Admin) auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(TokenHeader)
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			web.NewGinActionImpl(c).ThrowError(web.UNAUTHORIZED)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.config.Token)) != 1 {
+			web.NewGinActionImpl(c).ThrowError(web.InvalidToken)
+			return
+		}
+		c.Next()
+	}
+}
+
+// pprofHandler 转发到 net/http/pprof 支持 go tool pprof 直接访问
+func pprofHandler(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// goroutines 输出所有goroutine的堆栈
+func goroutines(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			_, _ = c.Writer.Write(buf[:n])
+			return
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// Stats 运行时状态
+type Stats struct {
+	Uptime        string  `json:"uptime"`
+	Goroutines    int     `json:"goroutines"`
+	NumCPU        int     `json:"num_cpu"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	HeapAlloc     uint64  `json:"heap_alloc"`
+	HeapInuse     uint64  `json:"heap_inuse"`
+	HeapIdle      uint64  `json:"heap_idle"`
+	HeapReleased  uint64  `json:"heap_released"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	Sys           uint64  `json:"sys"`
+	TotalAlloc    uint64  `json:"total_alloc"`
+	Mallocs       uint64  `json:"mallocs"`
+	Frees         uint64  `json:"frees"`
+	NumGC         uint32  `json:"num_gc"`
+	LastGC        string  `json:"last_gc"`
+	PauseTotal    string  `json:"pause_total"`
+	NextGC        uint64  `json:"next_gc"`
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+}
+
+func (a *Admin) stats(c *gin.Context) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := &Stats{
+		Uptime:        time.Since(a.started).Truncate(time.Second).String(),
+		Goroutines:    runtime.NumGoroutine(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapIdle:      m.HeapIdle,
+		HeapReleased:  m.HeapReleased,
+		HeapObjects:   m.HeapObjects,
+		Sys:           m.Sys,
+		TotalAlloc:    m.TotalAlloc,
+		Mallocs:       m.Mallocs,
+		Frees:         m.Frees,
+		NumGC:         m.NumGC,
+		PauseTotal:    time.Duration(m.PauseTotalNs).String(),
+		NextGC:        m.NextGC,
+		GCCPUFraction: m.GCCPUFraction,
+	}
+	if m.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC)).Format("2006-01-02 15:04:05")
+	}
+	web.NewGinActionImpl(c).Success(stats)
+}
+
+// BuildInfo 构建信息
+type BuildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"`
+	Deps      map[string]string `json:"deps"`
+}
+
+func build(c *gin.Context) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		web.NewGinActionImpl(c).Error("无法读取构建信息")
+		return
+	}
+	result := &BuildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Main.Path,
+		Version:   info.Main.Version,
+		Settings:  make(map[string]string, len(info.Settings)),
+		Deps:      make(map[string]string, len(info.Deps)),
+	}
+	for _, s := range info.Settings {
+		result.Settings[s.Key] = s.Value
+	}
+	for _, d := range info.Deps {
+		result.Deps[d.Path] = d.Version
+	}
+	web.NewGinActionImpl(c).Success(result)
+}
+
+type logLevelParam struct {
+	Level string `json:"level" form:"level" binding:"required,oneof=panic fatal error warn warning info debug trace"`
+}
+
+func (a *Admin) logLevel(c *gin.Context) {
+	web.NewGinActionImpl(c).Success(gin.H{"level": a.loggers[0].GetLevel().String()})
+}
+
+func (a *Admin) setLogLevel(c *gin.Context) {
+	action := web.NewGinActionImpl(c)
+	param := &logLevelParam{}
+	if err := action.BindParam(param); err != nil {
+		action.ThrowValidateError(err)
+		return
+	}
+	level, err := logrus.ParseLevel(param.Level)
+	if err != nil {
+		action.Error(err)
+		return
+	}
+	for _, l := range a.loggers {
+		l.SetLevel(level)
+	}
+	action.UpdateOK()
+}
+
+// Route 路由信息
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+func (a *Admin) listRoutes(c *gin.Context) {
+	routes := make([]Route, 0)
+	for _, engine := range a.engines {
+		for _, r := range engine.Routes() {
+			routes = append(routes, Route{Method: r.Method, Path: r.Path, Handler: r.Handler})
+		}
+	}
+	web.NewGinActionImpl(c).Success(routes)
+}
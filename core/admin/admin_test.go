@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+	"github.com/sirupsen/logrus"
+)
+
+func newEngine(a *Admin) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	a.routes(engine)
+	return engine
+}
+
+func do(engine *gin.Engine, method, target string, body string, header map[string]string) (*httptest.ResponseRecorder, *web.Response) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	res := &web.Response{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	return w, res
+}
+
+func TestAuth(t *testing.T) {
+	engine := newEngine(New(Config{Enable: true, Token: "secret"}))
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		status int
+		code   int
+	}{
+		{"missing", "/debug/build", nil, http.StatusUnauthorized, web.UNAUTHORIZED.Code},
+		{"query not supported", "/debug/build?token=secret", nil, http.StatusUnauthorized, web.UNAUTHORIZED.Code},
+		{"wrong", "/debug/build", map[string]string{TokenHeader: "wrong"}, http.StatusUnauthorized, web.InvalidToken.Code},
+		{"header", "/debug/build", map[string]string{TokenHeader: "secret"}, http.StatusOK, 0},
+		{"bearer", "/debug/build", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, res := do(engine, http.MethodGet, tt.target, "", tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d %s", w.Code, w.Body.String())
+			}
+			if tt.code != 0 && res.Code != tt.code {
+				t.Errorf("code = %d", res.Code)
+			}
+		})
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	first, second := logrus.New(), logrus.New()
+	engine := newEngine(New(Config{Enable: true, Token: "secret"}, WithLoggers(first, second)))
+	header := map[string]string{TokenHeader: "secret"}
+
+	if w, _ := do(engine, http.MethodPut, "/debug/log-level", `{"level":"verbose"}`, header); w.Code == http.StatusOK {
+		t.Errorf("invalid level accepted: %s", w.Body.String())
+	}
+	if w, _ := do(engine, http.MethodPut, "/debug/log-level", `{"level":"debug"}`, header); w.Code != http.StatusOK {
+		t.Fatalf("set level = %d %s", w.Code, w.Body.String())
+	}
+	if first.GetLevel() != logrus.DebugLevel || second.GetLevel() != logrus.DebugLevel {
+		t.Errorf("levels = %s %s", first.GetLevel(), second.GetLevel())
+	}
+	_, res := do(engine, http.MethodGet, "/debug/log-level", "", header)
+	if data, _ := res.Result.(map[string]any); data["level"] != "debug" {
+		t.Errorf("level = %v", res.Result)
+	}
+}
+
+func TestEmptyLoggers(t *testing.T) {
+	a := New(Config{Enable: true, Token: "secret"}, WithLoggers())
+	if len(a.loggers) != 1 || a.loggers[0] != logrus.StandardLogger() {
+		t.Fatalf("loggers = %v", a.loggers)
+	}
+	w, _ := do(newEngine(a), http.MethodGet, "/debug/log-level", "", map[string]string{TokenHeader: "secret"})
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d", w.Code)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	a := New(Config{})
+	if err := a.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Fatal() != nil {
+		t.Error("disabled admin returned a fatal channel")
+	}
+	if err := a.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(Config{Enable: true}).Init(nil); err != ErrTokenRequired {
+		t.Errorf("Init = %v", err)
+	}
+}
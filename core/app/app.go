@@ -0,0 +1,220 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/lshaofan/cb-framework/interfaces"
+)
+
+var (
+	// ErrDependencyCycle 组件之间存在循环依赖
+	ErrDependencyCycle = errors.New("组件存在循环依赖")
+	// ErrMissingDependency 依赖的组件未注册
+	ErrMissingDependency = errors.New("依赖的组件未注册")
+)
+
+// Closer 组件可选实现的接口 App.Close 时按初始化的相反顺序调用
+type Closer interface {
+	Close() error
+}
+
+type Option func(*component)
+
+// DependsOn 声明组件依赖的其他组件类型 被依赖的组件会先初始化 类型使用 TypeOf 获取
+func DependsOn(types ...reflect.Type) Option {
+	return func(c *component) {
+		c.deps = append(c.deps, types...)
+	}
+}
+
+// TypeOf 获取类型 用于 DependsOn 类型需要和 Register 的组件类型一致 例如 app.TypeOf[*config.Config[AppConfig]]()
+// *gorm.DB 等没有实现 interfaces.IGlobal 的值使用 Func 包装后注册 依赖时使用 Of
+func TypeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+type component struct {
+	value interfaces.IGlobal
+	typ   reflect.Type
+	deps  []reflect.Type
+}
+
+// App 应用容器 按依赖顺序初始化和关闭组件 组件之间通过类型互相获取 代替包级全局变量
+type App struct {
+	mu          sync.RWMutex
+	components  []*component
+	byType      map[reflect.Type]*component
+	initialized []*component
+}
+
+func New() *App {
+	return &App{
+		byType: make(map[reflect.Type]*component),
+	}
+}
+
+// Register 注册组件 组件按类型区分 同一类型重复注册会panic 没有实现 interfaces.IGlobal 的值使用 Func 包装
+func (a *App) Register(value interfaces.IGlobal, opts ...Option) *App {
+	if value == nil {
+		panic("注册的组件不能为空")
+	}
+	c := &component{
+		value: value,
+		typ:   reflect.TypeOf(value),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.byType[c.typ]; ok {
+		panic(fmt.Sprintf("组件 %s 重复注册", c.typ))
+	}
+	a.byType[c.typ] = c
+	a.components = append(a.components, c)
+	return a
+}
+
+// Init 按依赖的拓扑顺序调用组件的 Init 依赖缺失或存在循环依赖时不初始化任何组件
+// 某个组件初始化失败时 已初始化的组件会按相反顺序关闭
+func (a *App) Init() error {
+	order, err := a.resolve()
+	if err != nil {
+		return err
+	}
+	for _, c := range order {
+		if err := c.value.Init(); err != nil {
+			err = fmt.Errorf("初始化组件 %s 失败: %w", c.typ, err)
+			return errors.Join(err, a.Close())
+		}
+		a.mu.Lock()
+		a.initialized = append(a.initialized, c)
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// Close 按初始化的相反顺序调用组件的 Close
+func (a *App) Close() error {
+	a.mu.Lock()
+	initialized := a.initialized
+	a.initialized = nil
+	a.mu.Unlock()
+
+	var errs []error
+	for i := len(initialized) - 1; i >= 0; i-- {
+		c := initialized[i]
+		if closer, ok := c.value.(Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("关闭组件 %s 失败: %w", c.typ, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolve 计算初始化顺序 依赖先于被依赖者 没有依赖关系的组件保持注册顺序
+func (a *App) resolve() ([]*component, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*component]int, len(a.components))
+	order := make([]*component, 0, len(a.components))
+	var path []*component
+
+	var visit func(c *component) error
+	visit = func(c *component) error {
+		switch state[c] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, cyclePath(path, c))
+		}
+		state[c] = visiting
+		path = append(path, c)
+		for _, typ := range c.deps {
+			dep, ok := a.lookup(typ)
+			if !ok {
+				return fmt.Errorf("%w: %s 依赖 %s", ErrMissingDependency, c.typ, typ)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[c] = visited
+		order = append(order, c)
+		return nil
+	}
+
+	for _, c := range a.components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// lookup 按类型查找组件 类型为接口时返回第一个实现了该接口的组件
+func (a *App) lookup(typ reflect.Type) (*component, bool) {
+	if c, ok := a.byType[typ]; ok {
+		return c, true
+	}
+	if typ.Kind() == reflect.Interface {
+		for _, c := range a.components {
+			if c.typ.Implements(typ) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// cyclePath 拼接循环依赖的路径 例如 *A -> *B -> *A
+func cyclePath(path []*component, c *component) string {
+	names := make([]string, 0, len(path)+1)
+	start := 0
+	for i, p := range path {
+		if p == c {
+			start = i
+			break
+		}
+	}
+	for _, p := range path[start:] {
+		names = append(names, p.typ.String())
+	}
+	names = append(names, c.typ.String())
+	return strings.Join(names, " -> ")
+}
+
+// Get 按类型获取已注册的组件 T 为接口时返回第一个实现了该接口的组件
+func Get[T any](a *App) (T, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var zero T
+	c, ok := a.lookup(TypeOf[T]())
+	if !ok {
+		return zero, false
+	}
+	v, ok := c.value.(T)
+	return v, ok
+}
+
+// MustGet 按类型获取已注册的组件 组件不存在时panic
+func MustGet[T any](a *App) T {
+	v, ok := Get[T](a)
+	if !ok {
+		panic(fmt.Sprintf("组件 %s 未注册", TypeOf[T]()))
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var events []string
+
+type Config struct{}
+
+func (c *Config) Init() error  { events = append(events, "init config"); return nil }
+func (c *Config) Close() error { events = append(events, "close config"); return nil }
+
+type DB struct{ app *App }
+
+func (d *DB) Init() error {
+	// 初始化时通过类型获取依赖的组件
+	if _, ok := Get[*Config](d.app); !ok {
+		return errors.New("config 未注册")
+	}
+	events = append(events, "init db")
+	return nil
+}
+func (d *DB) Close() error { events = append(events, "close db"); return nil }
+
+type HTTP struct{}
+
+func (h *HTTP) Init() error { events = append(events, "init http"); return nil }
+
+func TestAppOrder(t *testing.T) {
+	events = nil
+	a := New()
+	a.Register(&HTTP{}, DependsOn(TypeOf[*DB]()))
+	a.Register(&DB{app: a}, DependsOn(TypeOf[*Config]()))
+	a.Register(&Config{})
+
+	if err := a.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"init config", "init db", "init http", "close db", "close config"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v; want %v", events, want)
+	}
+}
+
+func TestAppCycle(t *testing.T) {
+	events = nil
+	a := New()
+	a.Register(&Config{}, DependsOn(TypeOf[*DB]()))
+	a.Register(&DB{app: a}, DependsOn(TypeOf[*Config]()))
+
+	err := a.Init()
+	if !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("got %v; want %v", err, ErrDependencyCycle)
+	}
+	if len(events) != 0 {
+		t.Errorf("存在循环依赖时不应初始化组件: %v", events)
+	}
+}
+
+func TestAppMissingDependency(t *testing.T) {
+	a := New()
+	a.Register(&HTTP{}, DependsOn(TypeOf[*DB]()))
+	if err := a.Init(); !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("got %v; want %v", err, ErrMissingDependency)
+	}
+}
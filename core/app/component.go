@@ -0,0 +1,60 @@
+package app
+
+import "reflect"
+
+// Component 把没有实现 interfaces.IGlobal 的值包装成组件 例如 *gorm.DB、*redis.Client、*web.HTTPServer
+// 注册的类型为 *Component[T] 依赖时使用 Of[T]() 获取时使用 Value[T]
+type Component[T any] struct {
+	value T
+	init  func() (T, error)
+	close func(T) error
+}
+
+// Func 创建组件 init 在 App.Init 时创建值 close 在 App.Close 时关闭值 不需要关闭时为nil
+//
+//	a.Register(app.Func(func() (*redis.Client, error) {
+//		client := redis.NewClient(&redis.Options{Addr: addr})
+//		return client, client.Ping(context.Background()).Err()
+//	}, (*redis.Client).Close))
+//	a.Register(&Cache{}, app.DependsOn(app.Of[*redis.Client]()))
+func Func[T any](init func() (T, error), close func(T) error) *Component[T] {
+	return &Component[T]{init: init, close: close}
+}
+
+// Init 实现 interfaces.IGlobal
+func (c *Component[T]) Init() error {
+	v, err := c.init()
+	if err != nil {
+		return err
+	}
+	c.value = v
+	return nil
+}
+
+// Close 实现 Closer
+func (c *Component[T]) Close() error {
+	if c.close == nil {
+		return nil
+	}
+	return c.close(c.value)
+}
+
+// Get 获取 Init 创建的值
+func (c *Component[T]) Get() T {
+	return c.value
+}
+
+// Of 获取 Func 创建的组件的类型 用于 DependsOn
+func Of[T any]() reflect.Type {
+	return TypeOf[*Component[T]]()
+}
+
+// Value 获取 Func 创建的组件的值 组件未注册时返回false
+func Value[T any](a *App) (T, bool) {
+	c, ok := Get[*Component[T]](a)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return c.Get(), true
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache 依赖redis客户端的组件
+type Cache struct {
+	app    *App
+	client *redis.Client
+}
+
+func (c *Cache) Init() error {
+	client, ok := Value[*redis.Client](c.app)
+	if !ok || client == nil {
+		return errors.New("redis 未初始化")
+	}
+	c.client = client
+	return client.Set(context.Background(), "cache:ready", 1, 0).Err()
+}
+
+func TestFunc(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := New()
+	// 依赖的组件在被依赖的组件之前注册
+	cache := &Cache{app: a}
+	a.Register(cache, DependsOn(Of[*redis.Client]()))
+	a.Register(Func(func() (*redis.Client, error) {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		return client, client.Ping(context.Background()).Err()
+	}, (*redis.Client).Close))
+
+	if err := a.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("cache:ready") {
+		t.Error("cache was initialized before redis")
+	}
+	client, ok := Value[*redis.Client](a)
+	if !ok || client != cache.client {
+		t.Fatalf("value = %v, %v", client, ok)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(context.Background()).Err(); !errors.Is(err, redis.ErrClosed) {
+		t.Errorf("ping after close = %v", err)
+	}
+}
+
+func TestFuncError(t *testing.T) {
+	closed := false
+	a := New()
+	a.Register(Func(func() (string, error) { return "config", nil }, func(string) error {
+		closed = true
+		return nil
+	}))
+	a.Register(Func(func() (int, error) { return 0, errors.New("connect failed") }, nil), DependsOn(Of[string]()))
+
+	if err := a.Init(); err == nil {
+		t.Fatal("init succeeded")
+	}
+	// 初始化失败时已初始化的组件被关闭
+	if !closed {
+		t.Error("initialized component was not closed")
+	}
+	if _, ok := Value[float64](a); ok {
+		t.Error("unregistered value found")
+	}
+}
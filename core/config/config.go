@@ -0,0 +1,397 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lshaofan/cb-framework/server/web"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+type Option func(*options)
+
+type options struct {
+	files         []file
+	envPrefix     string
+	watchInterval time.Duration
+	onError       func(error)
+}
+
+type file struct {
+	path     string
+	optional bool
+}
+
+// WithFiles 设置配置文件 支持yaml和json 按顺序加载 后面文件中的值覆盖前面的
+func WithFiles(paths ...string) Option {
+	return func(o *options) {
+		for _, p := range paths {
+			o.files = append(o.files, file{path: p})
+		}
+	}
+}
+
+// WithOptionalFiles 设置可选的配置文件 文件不存在时跳过 例如本地开发使用的 config.local.yaml
+func WithOptionalFiles(paths ...string) Option {
+	return func(o *options) {
+		for _, p := range paths {
+			o.files = append(o.files, file{path: p, optional: true})
+		}
+	}
+}
+
+// WithEnvPrefix 设置环境变量前缀 环境变量的值覆盖配置文件中的值
+// 字段对应的环境变量为 前缀_字段路径 字段名取yaml或json标签并转为大写 例如 APP_MYSQL_HOST
+// 字段上有env标签时直接使用env标签的值作为环境变量名 未设置前缀时只有带env标签的字段可以被环境变量覆盖
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithWatchInterval 设置检查配置文件是否修改的间隔 默认5秒
+func WithWatchInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.watchInterval = interval
+	}
+}
+
+// WithErrorHandler 设置热更新失败时的处理函数 默认记录日志并继续使用旧的配置
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// Config 类型化的配置 实现 interfaces.IGlobal 可以注册到 app.App
+type Config[T any] struct {
+	opts *options
+
+	mu          sync.RWMutex
+	value       *T
+	subscribers []func(old, new *T)
+
+	modTimes map[string]time.Time
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New[T any](opts ...Option) *Config[T] {
+	o := &options{
+		watchInterval: 5 * time.Second,
+		onError: func(err error) {
+			logrus.WithError(err).Error("config: reload failed")
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Config[T]{
+		opts:     o,
+		modTimes: make(map[string]time.Time),
+	}
+}
+
+// Load 加载配置文件到T 使用环境变量覆盖并校验
+func Load[T any](opts ...Option) (*T, error) {
+	c := New[T](opts...)
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	return c.Get(), nil
+}
+
+// Init 加载配置
+func (c *Config[T]) Init() error {
+	value, err := c.load()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.value = value
+	c.mu.Unlock()
+	return nil
+}
+
+// Get 获取当前的配置 返回值不能修改
+func (c *Config[T]) Get() *T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.value
+}
+
+// Subscribe 订阅配置变化 配置文件修改并重新加载成功后调用
+func (c *Config[T]) Subscribe(fn func(old, new *T)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, fn)
+}
+
+// Watch 开始监听配置文件的修改 修改后重新加载配置并通知订阅者
+func (c *Config[T]) Watch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+	go c.watch(c.stop)
+}
+
+// Close 停止监听配置文件
+func (c *Config[T]) Close() error {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		c.wg.Wait()
+	}
+	return nil
+}
+
+// Reload 重新加载配置 成功后通知订阅者 失败时保留旧的配置
+func (c *Config[T]) Reload() error {
+	value, err := c.load()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	old := c.value
+	c.value = value
+	subscribers := append([]func(old, new *T){}, c.subscribers...)
+	c.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, value)
+	}
+	return nil
+}
+
+func (c *Config[T]) watch(stop chan struct{}) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.opts.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				c.opts.onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// changed 判断配置文件的修改时间是否变化
+func (c *Config[T]) changed() bool {
+	changed := false
+	for _, f := range c.opts.files {
+		var modTime time.Time
+		if info, err := os.Stat(f.path); err == nil {
+			modTime = info.ModTime()
+		}
+		c.mu.Lock()
+		if !c.modTimes[f.path].Equal(modTime) {
+			c.modTimes[f.path] = modTime
+			changed = true
+		}
+		c.mu.Unlock()
+	}
+	return changed
+}
+
+// load 按顺序加载配置文件 然后使用环境变量覆盖 最后校验
+func (c *Config[T]) load() (*T, error) {
+	value := new(T)
+	for _, f := range c.opts.files {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			if f.optional && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		c.mu.Lock()
+		c.modTimes[f.path] = info.ModTime()
+		c.mu.Unlock()
+		if err := decodeFile(f.path, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(value).Elem(), c.opts.envPrefix); err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(value).Elem().Kind() == reflect.Struct {
+		if err := web.NewValidate().ValidateStruct(value); err != nil {
+			return nil, web.NewRequest().GetValidateErr(err, value)
+		}
+	}
+	return value, nil
+}
+
+// decodeFile 根据扩展名解析配置文件
+func decodeFile(path string, value any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, value)
+	case ".json":
+		err = json.Unmarshal(data, value)
+	default:
+		return fmt.Errorf("config: 不支持的配置文件格式 %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: 解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 使用环境变量覆盖结构体字段 prefix为空时只处理带env标签的字段
+// 嵌套结构体仍然需要递归 其中的字段可能带有env标签
+func applyEnv(v reflect.Value, prefix string) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+		name := ""
+		if prefix != "" {
+			name = envName(f, prefix)
+		}
+
+		if key, ok := f.Tag.Lookup("env"); ok {
+			if err := setEnv(field, key); err != nil {
+				return err
+			}
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}):
+			if err := applyEnv(field, name); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+			if !field.IsNil() {
+				if err := applyEnv(field.Elem(), name); err != nil {
+					return err
+				}
+			}
+		case name != "":
+			if err := setEnv(field, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// envName 获取字段对应的环境变量名
+func envName(f reflect.StructField, prefix string) string {
+	name := f.Name
+	for _, tag := range []string{"yaml", "json"} {
+		if v, ok := f.Tag.Lookup(tag); ok {
+			if v = strings.Split(v, ",")[0]; v != "" && v != "-" {
+				name = v
+				break
+			}
+		}
+	}
+	name = strings.ToUpper(name)
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// setEnv 把环境变量的值设置到字段 环境变量不存在时不修改
+func setEnv(field reflect.Value, key string) error {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	if err := setValue(field, raw); err != nil {
+		return fmt.Errorf("config: 环境变量 %s 的值错误: %w", key, err)
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		// 切片使用逗号分隔
+		parts := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	case reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(elem)
+	default:
+		return fmt.Errorf("不支持的类型 %s", field.Type())
+	}
+	return nil
+}
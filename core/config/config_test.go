@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name  string `yaml:"name" json:"name" binding:"required"`
+	Mysql struct {
+		Host string `yaml:"host" json:"host"`
+		Port int    `yaml:"port" json:"port"`
+	} `yaml:"mysql" json:"mysql"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout" env:"TEST_TIMEOUT"`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "name: app\nmysql:\n  host: localhost\n  port: 3306\n")
+	writeFile(t, filepath.Join(dir, "config.json"), `{"mysql": {"port": 3307}}`)
+	t.Setenv("APP_MYSQL_HOST", "db")
+	t.Setenv("TEST_TIMEOUT", "3s")
+
+	cfg, err := Load[testConfig](
+		WithFiles(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.json")),
+		WithOptionalFiles(filepath.Join(dir, "config.local.yaml")),
+		WithEnvPrefix("APP"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "app" || cfg.Mysql.Host != "db" || cfg.Mysql.Port != 3307 || cfg.Timeout != 3*time.Second {
+		t.Errorf("配置加载错误: %+v", cfg)
+	}
+}
+
+func TestLoadWithoutPrefix(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "name: app\nmysql:\n  host: localhost\n")
+	// 没有前缀时只有env标签的字段可以被覆盖
+	t.Setenv("MYSQL_HOST", "db")
+	t.Setenv("HOST", "db")
+	t.Setenv("NAME", "other")
+	t.Setenv("TEST_TIMEOUT", "3s")
+
+	cfg, err := Load[testConfig](WithFiles(filepath.Join(dir, "config.yaml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "app" || cfg.Mysql.Host != "localhost" || cfg.Timeout != 3*time.Second {
+		t.Errorf("配置加载错误: %+v", cfg)
+	}
+}
+
+func TestLoadValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "mysql:\n  port: 3306\n")
+	if _, err := Load[testConfig](WithFiles(filepath.Join(dir, "config.yaml"))); err == nil {
+		t.Error("缺少name时应该校验失败")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "name: old\n")
+
+	c := New[testConfig](WithFiles(path), WithWatchInterval(10*time.Millisecond))
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan [2]string, 1)
+	c.Subscribe(func(old, new *testConfig) {
+		changed <- [2]string{old.Name, new.Name}
+	})
+	c.Watch()
+	defer c.Close()
+
+	writeFile(t, path, "name: new\n")
+	_ = os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	select {
+	case names := <-changed:
+		if names != [2]string{"old", "new"} {
+			t.Errorf("got %v", names)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("配置修改后没有通知订阅者")
+	}
+}
@@ -0,0 +1,227 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+const (
+	// StatusUp 组件正常
+	StatusUp = "up"
+	// StatusDown 组件异常
+	StatusDown = "down"
+
+	// DefaultTimeout 默认的单个检查超时时间
+	DefaultTimeout = 3 * time.Second
+)
+
+var (
+	// ErrTimeout 检查超时
+	ErrTimeout = errors.New("health check timeout")
+)
+
+// HealthChecker 健康检查接口 服务、数据库、redis、微信客户端等组件实现此接口后即可注册到 Registry
+type HealthChecker interface {
+	// HealthCheck 检查组件是否健康 返回nil表示健康
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckerFunc 函数形式的 HealthChecker
+type CheckerFunc func(ctx context.Context) error
+
+// HealthCheck 实现 HealthChecker
+func (f CheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckOption func(*check)
+
+// WithTimeout 设置单个检查的超时时间
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = timeout
+	}
+}
+
+// WithCacheTTL 设置检查结果的缓存时间 缓存时间内重复请求直接返回上次的结果
+func WithCacheTTL(ttl time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithLiveness 检查同时用于存活探针 /healthz 默认只用于就绪探针 /readyz
+func WithLiveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+// ComponentStatus 单个组件的检查结果
+type ComponentStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report 所有组件的检查结果
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// IsUp 判断所有组件是否正常
+func (r *Report) IsUp() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name     string
+	checker  HealthChecker
+	timeout  time.Duration
+	cacheTTL time.Duration
+	liveness bool
+
+	mu     sync.Mutex
+	cached *ComponentStatus
+}
+
+// run 执行检查 缓存未过期时返回缓存结果
+func (c *check) run(ctx context.Context) ComponentStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached != nil && time.Since(c.cached.CheckedAt) < c.cacheTTL {
+		return *c.cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checker.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	status := ComponentStatus{
+		Status:    StatusUp,
+		Latency:   time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	c.cached = &status
+	return status
+}
+
+// Registry 健康检查注册表 汇总所有组件的检查结果
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册健康检查 name 重复时覆盖之前的注册
+func (r *Registry) Register(name string, checker HealthChecker, opts ...CheckOption) {
+	c := &check{
+		name:    name,
+		checker: checker,
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, old := range r.checks {
+		if old.name == name {
+			r.checks[i] = c
+			return
+		}
+	}
+	r.checks = append(r.checks, c)
+}
+
+// Liveness 执行存活检查 只包含注册时使用了 WithLiveness 的组件
+func (r *Registry) Liveness(ctx context.Context) *Report {
+	return r.run(ctx, true)
+}
+
+// Readiness 执行就绪检查 包含所有组件
+func (r *Registry) Readiness(ctx context.Context) *Report {
+	return r.run(ctx, false)
+}
+
+func (r *Registry) run(ctx context.Context, liveness bool) *Report {
+	r.mu.RLock()
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		if !liveness || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := &Report{
+		Status:     StatusUp,
+		Components: make(map[string]ComponentStatus, len(checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			status := c.run(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[c.name] = status
+			if status.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+// Healthz 存活探针 gin 处理函数 挂载到 /healthz
+func (r *Registry) Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		render(c, r.Liveness(c.Request.Context()))
+	}
+}
+
+// Readyz 就绪探针 gin 处理函数 挂载到 /readyz
+func (r *Registry) Readyz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		render(c, r.Readiness(c.Request.Context()))
+	}
+}
+
+// render 使用 web.Response 返回检查结果 异常时返回503
+func render(c *gin.Context, report *Report) {
+	if report.IsUp() {
+		c.AbortWithStatusJSON(http.StatusOK, web.NewResponse(web.SUCCESS, web.Succeed, report))
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, web.NewResponse(web.ERROR, "服务不可用", report))
+}
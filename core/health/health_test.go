@@ -0,0 +1,36 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	calls := 0
+	r.Register("db", CheckerFunc(func(ctx context.Context) error {
+		calls++
+		return nil
+	}), WithLiveness(), WithCacheTTL(time.Minute))
+	r.Register("redis", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), WithTimeout(10*time.Millisecond))
+
+	live := r.Liveness(context.Background())
+	if !live.IsUp() || len(live.Components) != 1 {
+		t.Errorf("存活检查结果错误: %+v", live)
+	}
+
+	ready := r.Readiness(context.Background())
+	if ready.IsUp() {
+		t.Error("redis 超时后就绪检查应该失败")
+	}
+	if ready.Components["redis"].Status != StatusDown {
+		t.Errorf("redis 状态错误: %+v", ready.Components["redis"])
+	}
+	if calls != 1 {
+		t.Errorf("缓存未生效 调用次数: %d", calls)
+	}
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
-	"fmt"
+	"errors"
+	"os"
+	"path/filepath"
 	//"log"
+	"sync"
 	"time"
 
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
@@ -91,20 +94,64 @@ import (
 //	return l.Logger
 //}
 
-// GetOutput 获取 SetOutput
+var (
+	// outputs GetOutput 创建的writer 按 path/name 缓存 同一个日志只创建一个writer
+	outputs   = make(map[string]*rotatelogs.RotateLogs)
+	outputsMu sync.Mutex
+
+	// clock writer 按天切割使用的时钟 测试时替换
+	clock rotatelogs.Clock = rotatelogs.Local
+)
+
+// GetOutput 获取 SetOutput 同一个日志返回同一个writer
+// 日志文件保存在 ./runtime/log/<年-月-日>/<path>/<name>.log.<年月日> 跨天时writer自动写入新一天的目录
+// ./runtime/log/<path>/<name>.log 为指向当前文件的链接
 func GetOutput(path, name string) (*rotatelogs.RotateLogs, error) {
-	// 获取当前时间
-	now := time.Now()
-	// 获取当前年月日
-	year, month, day := now.Date()
-	nowTime := fmt.Sprintf("%d-%d-%d", year, month, day)
-	filePath := fmt.Sprintf("./runtime/log/%s/%s/%s.log", nowTime, path, name)
-	return rotatelogs.New(
-		filePath+".%Y%m%d",
-		rotatelogs.WithLinkName(filePath),
+	key := path + "/" + name
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	if writer, ok := outputs[key]; ok {
+		return writer, nil
+	}
+	// 使用绝对路径 链接文件和日志文件不在同一个目录
+	root, err := filepath.Abs("./runtime/log")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := rotatelogs.New(
+		// 目录中的日期由writer按当前时间生成
+		filepath.Join(root, "%Y-%m-%d", path, name+".log.%Y%m%d"),
+		rotatelogs.WithLinkName(filepath.Join(root, path, name+".log")),
+		rotatelogs.WithClock(clock),
 		rotatelogs.WithRotationTime(24*time.Hour),  //最小为1分钟轮询。默认60s  低于1分钟就按1分钟来
 		rotatelogs.WithRotationCount(7),            //设置7份 大于7份 或到了清理时间 开始清理
 		rotatelogs.WithRotationSize(100*1024*1024), //设置100MB大小,当大于这个容量时，创建新的日志文件
 
 	)
+	if err != nil {
+		return nil, err
+	}
+	outputs[key] = writer
+	return writer, nil
+}
+
+// Reopen 重新打开 GetOutput 创建的日志中当前文件已经被外部工具移动或删除的日志
+// 当前文件仍然存在的日志不做处理 重新打开的文件名带有序号后缀 链接文件指向新的文件
+func Reopen() error {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	var errs []error
+	for _, w := range outputs {
+		name := w.CurrentFileName()
+		if name == "" {
+			continue
+		}
+		if _, err := os.Stat(name); err == nil {
+			continue
+		}
+		if err := w.Rotate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+)
+
+func chdir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return dir
+}
+
+func TestGetOutput(t *testing.T) {
+	chdir(t)
+	first, err := GetOutput("test", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := GetOutput("test", "cache")
+	other, _ := GetOutput("test", "other")
+	if first != second || first == other {
+		t.Error("同一个日志应该返回同一个writer")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := chdir(t)
+	moved, err := GetOutput("test", "moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := GetOutput("test", "kept")
+	for _, w := range []io.Writer{moved, kept} {
+		if _, err := w.Write([]byte("before\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keptName := kept.CurrentFileName()
+	// 模拟日志文件被外部工具移动
+	if err := os.Rename(moved.CurrentFileName(), filepath.Join(dir, "moved.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moved.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(moved.CurrentFileName())
+	if err != nil || string(data) != "after\n" {
+		t.Errorf("重新打开后的内容 = %q %v", data, err)
+	}
+	// 链接文件指向新的文件
+	link := filepath.Join(dir, "runtime", "log", "test", "moved.log")
+	if data, err := os.ReadFile(link); err != nil || string(data) != "after\n" {
+		t.Errorf("链接文件的内容 = %q %v", data, err)
+	}
+	// 文件没有移动的日志不重新打开
+	if kept.CurrentFileName() != keptName {
+		t.Errorf("文件名 = %s", kept.CurrentFileName())
+	}
+	if matches, _ := filepath.Glob(keptName + ".*"); len(matches) != 0 {
+		t.Errorf("多余的文件 %v", matches)
+	}
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
+
+func TestGetOutputAcrossDays(t *testing.T) {
+	dir := chdir(t)
+	fake := &fakeClock{now: time.Date(2024, 3, 1, 23, 59, 0, 0, time.Local)}
+	clock = fake
+	defer func() { clock = rotatelogs.Local }()
+
+	w, err := GetOutput("test", "days")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	// 跨过零点后写入新一天的目录
+	fake.set(time.Date(2024, 3, 2, 0, 1, 0, 0, time.Local))
+	if _, err := w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{
+		filepath.Join(dir, "runtime", "log", "2024-03-01", "test", "days.log.20240301"): "first\n",
+		filepath.Join(dir, "runtime", "log", "2024-03-02", "test", "days.log.20240302"): "second\n",
+		filepath.Join(dir, "runtime", "log", "test", "days.log"):                        "second\n",
+	} {
+		if data, err := os.ReadFile(file); err != nil || string(data) != want {
+			t.Errorf("%s = %q %v", file, data, err)
+		}
+	}
+	if again, _ := GetOutput("test", "days"); again != w {
+		t.Error("跨天后应该返回同一个writer")
+	}
+}
@@ -0,0 +1,25 @@
+package orm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// HealthChecker 数据库健康检查 实现 health.HealthChecker
+type HealthChecker struct {
+	DB *gorm.DB
+}
+
+func NewHealthChecker(db *gorm.DB) *HealthChecker {
+	return &HealthChecker{DB: db}
+}
+
+// HealthCheck ping 数据库
+func (h *HealthChecker) HealthCheck(ctx context.Context) error {
+	sqlDB, err := h.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
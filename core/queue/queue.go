@@ -0,0 +1,465 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lshaofan/cb-framework/server"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultPrefix redis key的默认前缀
+	DefaultPrefix = "queue:"
+	// DefaultName 默认的队列名称
+	DefaultName = "default"
+)
+
+var (
+	// ErrUnknownJobType 任务类型未注册 此类任务直接进入死信队列
+	ErrUnknownJobType = errors.New("未注册的任务类型")
+)
+
+// Job 队列中的任务
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	MaxRetries int             `json:"max_retries"`
+	Attempts   int             `json:"attempts"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	LastError  string          `json:"last_error,omitempty"`
+}
+
+// Handler 任务处理函数 返回错误时任务会重试 超过重试次数后进入死信队列
+type Handler func(ctx context.Context, job *Job) error
+
+type Option func(*Queue)
+
+// WithName 设置队列名称 默认为 default
+func WithName(name string) Option {
+	return func(q *Queue) {
+		q.name = name
+	}
+}
+
+// WithPrefix 设置redis key的前缀 默认为 queue:
+func WithPrefix(prefix string) Option {
+	return func(q *Queue) {
+		q.prefix = prefix
+	}
+}
+
+// WithConcurrency 设置worker的数量 默认为10
+func WithConcurrency(n int) Option {
+	return func(q *Queue) {
+		q.concurrency = n
+	}
+}
+
+// WithVisibilityTimeout 设置任务的可见性超时 任务执行超过此时间未确认时认为worker已崩溃 任务重新入队
+// 同时也是任务ctx的超时时间 默认5分钟
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(q *Queue) {
+		q.visibilityTimeout = timeout
+	}
+}
+
+// WithPollInterval 设置队列为空时拉取任务的间隔 默认1秒
+func WithPollInterval(interval time.Duration) Option {
+	return func(q *Queue) {
+		q.pollInterval = interval
+	}
+}
+
+// WithRetryBackoff 设置重试的退避时间 每次重试翻倍 默认1秒到10分钟
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(q *Queue) {
+		q.initialBackoff = initial
+		q.maxBackoff = max
+	}
+}
+
+// WithMaxRetries 设置任务默认的最大重试次数 默认3次
+func WithMaxRetries(n int) Option {
+	return func(q *Queue) {
+		q.maxRetries = n
+	}
+}
+
+// WithLogger 设置日志 默认使用logrus的标准日志
+func WithLogger(l logrus.FieldLogger) Option {
+	return func(q *Queue) {
+		q.logger = l
+	}
+}
+
+// Queue 基于redis的任务队列 实现 server.Service 启动后运行worker池处理任务
+type Queue struct {
+	client            *redis.Client
+	name              string
+	prefix            string
+	concurrency       int
+	visibilityTimeout time.Duration
+	pollInterval      time.Duration
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	maxRetries        int
+	logger            logrus.FieldLogger
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	cancel    context.CancelFunc // 停止拉取任务
+	runCancel context.CancelFunc // 取消正在执行的任务
+	workers   sync.WaitGroup
+}
+
+func New(client *redis.Client, opts ...Option) *Queue {
+	q := &Queue{
+		client:            client,
+		name:              DefaultName,
+		prefix:            DefaultPrefix,
+		concurrency:       10,
+		visibilityTimeout: 5 * time.Minute,
+		pollInterval:      time.Second,
+		initialBackoff:    time.Second,
+		maxBackoff:        10 * time.Minute,
+		maxRetries:        3,
+		logger:            logrus.StandardLogger(),
+		handlers:          make(map[string]Handler),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Handle 注册任务处理函数 推荐使用类型安全的 Register
+func (q *Queue) Handle(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Register 注册类型化的任务处理函数 payload 从json解析为T
+func Register[T any](q *Queue, jobType string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(jobType, func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return fmt.Errorf("解析任务 %s 的参数失败: %w", job.Type, err)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	runAt      time.Time
+	maxRetries *int
+}
+
+// WithDelay 延迟执行
+func WithDelay(delay time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = time.Now().Add(delay)
+	}
+}
+
+// WithRunAt 在指定的时间执行
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+// WithRetries 设置此任务的最大重试次数
+func WithRetries(n int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.maxRetries = &n
+	}
+}
+
+// Enqueue 添加任务 payload 使用json序列化 返回任务id
+func Enqueue[T any](ctx context.Context, q *Queue, jobType string, payload T, opts ...EnqueueOption) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return q.Enqueue(ctx, jobType, data, opts...)
+}
+
+// Enqueue 添加已经序列化的任务 返回任务id
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload []byte, opts ...EnqueueOption) (string, error) {
+	o := &enqueueOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	job := &Job{
+		ID:         newID(),
+		Type:       jobType,
+		Payload:    payload,
+		MaxRetries: q.maxRetries,
+		EnqueuedAt: time.Now(),
+	}
+	if o.maxRetries != nil {
+		job.MaxRetries = *o.maxRetries
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.key("jobs"), job.ID, data)
+		if o.runAt.After(time.Now()) {
+			pipe.ZAdd(ctx, q.key("scheduled"), redis.Z{Score: float64(o.runAt.UnixMilli()), Member: job.ID})
+		} else {
+			pipe.LPush(ctx, q.key("ready"), job.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Dead 获取死信队列中最新的 limit 个任务
+func (q *Queue) Dead(ctx context.Context, limit int64) ([]*Job, error) {
+	list, err := q.client.LRange(ctx, q.key("dead"), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(list))
+	for _, data := range list {
+		job := new(Job)
+		if err := json.Unmarshal([]byte(data), job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Name 实现 server.Namer
+func (q *Queue) Name() string {
+	return "queue:" + q.name
+}
+
+// Init 实现 server.Service
+func (q *Queue) Init(server.Environment) error {
+	return nil
+}
+
+// Start 实现 server.Service 启动worker池
+func (q *Queue) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, runCancel := context.WithCancel(context.Background())
+	q.cancel, q.runCancel = cancel, runCancel
+
+	q.workers.Add(1)
+	go q.promote(ctx)
+	for i := 0; i < q.concurrency; i++ {
+		q.workers.Add(1)
+		go q.work(ctx, runCtx)
+	}
+	return nil
+}
+
+// Stop 实现 server.Service 停止拉取新任务并等待正在执行的任务结束
+func (q *Queue) Stop() error {
+	return q.StopContext(context.Background())
+}
+
+// StopContext 实现 server.ContextStopper 停止拉取新任务并等待正在执行的任务结束
+// ctx 结束时取消正在执行的任务 被取消的任务立即放回就绪队列 不计入重试次数
+func (q *Queue) StopContext(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+	defer q.runCancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.runCancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// promote 定时把到期的延迟任务和超时未确认的任务移动到就绪队列
+func (q *Queue) promote(ctx context.Context) {
+	defer q.workers.Done()
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		keys := []string{q.key("scheduled"), q.key("ready"), q.key("processing")}
+		if err := promoteScript.Run(ctx, q.client, keys, time.Now().UnixMilli(), 1000).Err(); err != nil && ctx.Err() == nil {
+			q.logger.WithError(err).Error("queue: 移动到期任务失败")
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// work 循环拉取并执行任务
+func (q *Queue) work(ctx, runCtx context.Context) {
+	defer q.workers.Done()
+	for ctx.Err() == nil {
+		job, err := q.fetch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				q.logger.WithError(err).Error("queue: 拉取任务失败")
+			}
+		}
+		if job == nil {
+			select {
+			case <-time.After(q.pollInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if job.Attempts > job.MaxRetries+1 {
+			// 任务在执行中导致worker崩溃时不会调用 process 只能在重新拉取时检查执行次数
+			job.LastError = "超过最大执行次数 任务可能导致worker崩溃"
+			q.logger.WithFields(logrus.Fields{"job_id": job.ID, "job_type": job.Type, "attempts": job.Attempts}).
+				Error("queue: 任务执行次数超过限制 进入死信队列")
+			q.fail(job, "")
+			continue
+		}
+		q.process(runCtx, job)
+	}
+}
+
+// fetch 从就绪队列取出一个任务 并放入处理中队列
+func (q *Queue) fetch(ctx context.Context) (*Job, error) {
+	keys := []string{q.key("ready"), q.key("processing"), q.key("jobs"), q.key("attempts")}
+	deadline := time.Now().Add(q.visibilityTimeout).UnixMilli()
+	res, err := fetchScript.Run(ctx, q.client, keys, deadline).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := new(Job)
+	if err := json.Unmarshal([]byte(res[0]), job); err != nil {
+		return nil, err
+	}
+	job.Attempts, _ = strconv.Atoi(res[1])
+	return job, nil
+}
+
+// process 执行任务 成功后确认 失败时重试或进入死信队列
+func (q *Queue) process(ctx context.Context, job *Job) {
+	logger := q.logger.WithFields(logrus.Fields{"job_id": job.ID, "job_type": job.Type, "attempts": job.Attempts})
+
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = ErrUnknownJobType
+	} else {
+		err = q.call(ctx, handler, job)
+	}
+
+	// 任务被取消时也需要确认或重试 使用新的ctx
+	ackCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err == nil {
+		keys := []string{q.key("processing"), q.key("jobs"), q.key("attempts")}
+		if err := ackScript.Run(ackCtx, q.client, keys, job.ID).Err(); err != nil {
+			logger.WithError(err).Error("queue: 确认任务失败")
+		}
+		return
+	}
+
+	// 停止服务时被取消的任务不是任务本身的错误 放回队列由之后的worker执行
+	if ctx.Err() != nil {
+		keys := []string{q.key("processing"), q.key("ready"), q.key("attempts")}
+		if err := requeueScript.Run(ackCtx, q.client, keys, job.ID).Err(); err != nil {
+			logger.WithError(err).Error("queue: 任务重新入队失败")
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+	retryAt := ""
+	if ok && job.Attempts <= job.MaxRetries {
+		retryAt = strconv.FormatInt(time.Now().Add(q.backoff(job.Attempts)).UnixMilli(), 10)
+		logger.WithError(err).Warn("queue: 任务执行失败 稍后重试")
+	} else {
+		logger.WithError(err).Error("queue: 任务执行失败 进入死信队列")
+	}
+	q.fail(job, retryAt)
+}
+
+// fail 保存任务的失败状态 retryAt 为空时进入死信队列 否则在 retryAt 重试
+func (q *Queue) fail(job *Job, retryAt string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	data, _ := json.Marshal(job)
+	keys := []string{q.key("processing"), q.key("scheduled"), q.key("jobs"), q.key("attempts"), q.key("dead")}
+	if err := failScript.Run(ctx, q.client, keys, job.ID, data, retryAt).Err(); err != nil {
+		q.logger.WithError(err).WithField("job_id", job.ID).Error("queue: 保存任务失败状态失败")
+	}
+}
+
+// call 执行任务处理函数 panic 作为错误返回
+func (q *Queue) call(ctx context.Context, handler Handler, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, q.visibilityTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			q.logger.WithField("stack", string(debug.Stack())).Errorf("queue: 任务panic: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff 第 attempt 次失败后的重试等待时间
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.initialBackoff
+	for i := 1; i < attempt && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.maxBackoff {
+		d = q.maxBackoff
+	}
+	return d
+}
+
+func (q *Queue) key(name string) string {
+	return q.prefix + q.name + ":" + name
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,280 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+type payload struct {
+	N int `json:"n"`
+}
+
+func newQueue(t *testing.T, opts ...Option) (*Queue, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	opts = append([]Option{
+		WithConcurrency(2),
+		WithPollInterval(10 * time.Millisecond),
+		WithRetryBackoff(10*time.Millisecond, 20*time.Millisecond),
+		WithLogger(logger),
+	}, opts...)
+	return New(client, opts...), mr
+}
+
+func start(t *testing.T, q *Queue) {
+	t.Helper()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.Stop() })
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	q, mr := newQueue(t)
+	var sum atomic.Int64
+	Register(q, "add", func(ctx context.Context, p payload) error {
+		sum.Add(int64(p.N))
+		return nil
+	})
+	start(t, q)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := Enqueue(context.Background(), q, "add", payload{N: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, func() bool { return sum.Load() == 6 })
+	// 确认后删除任务
+	eventually(t, func() bool { return !mr.Exists(q.key("jobs")) })
+}
+
+func TestDelayedJob(t *testing.T) {
+	q, _ := newQueue(t)
+	var ranAt atomic.Int64
+	Register(q, "delay", func(ctx context.Context, p payload) error {
+		ranAt.Store(time.Now().UnixMilli())
+		return nil
+	})
+	start(t, q)
+
+	enqueued := time.Now()
+	if _, err := Enqueue(context.Background(), q, "delay", payload{}, WithDelay(200*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return ranAt.Load() != 0 })
+	if d := time.UnixMilli(ranAt.Load()).Sub(enqueued); d < 200*time.Millisecond {
+		t.Errorf("delayed job ran after %s", d)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	q, _ := newQueue(t)
+	var attempts atomic.Int32
+	done := make(chan int, 1)
+	q.Handle("flaky", func(ctx context.Context, job *Job) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("temporary")
+		}
+		done <- job.Attempts
+		return nil
+	})
+	start(t, q)
+
+	if _, err := q.Enqueue(context.Background(), "flaky", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-done:
+		if n != 3 {
+			t.Errorf("attempts = %d", n)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not retried")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	q, _ := newQueue(t, WithMaxRetries(1))
+	var attempts atomic.Int32
+	q.Handle("broken", func(ctx context.Context, job *Job) error {
+		attempts.Add(1)
+		return errors.New("always fails")
+	})
+	start(t, q)
+
+	id, _ := q.Enqueue(context.Background(), "broken", []byte(`{}`))
+	if _, err := q.Enqueue(context.Background(), "unknown", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	var dead []*Job
+	eventually(t, func() bool {
+		dead, _ = q.Dead(context.Background(), 10)
+		return len(dead) == 2
+	})
+	if attempts.Load() != 2 {
+		t.Errorf("attempts = %d", attempts.Load())
+	}
+	for _, job := range dead {
+		if job.ID == id && (job.Attempts != 2 || job.LastError != "always fails") {
+			t.Errorf("dead job = %+v", job)
+		}
+		if job.Type == "unknown" && job.LastError != ErrUnknownJobType.Error() {
+			t.Errorf("unknown job = %+v", job)
+		}
+	}
+}
+
+func TestVisibilityTimeoutRequeue(t *testing.T) {
+	q, _ := newQueue(t, WithVisibilityTimeout(50*time.Millisecond), WithMaxRetries(1))
+	ctx := context.Background()
+	id, _ := q.Enqueue(ctx, "job", []byte(`{}`))
+	poison, _ := q.Enqueue(ctx, "poison", []byte(`{}`))
+
+	// 模拟worker拉取任务后崩溃 poison 任务每次都导致崩溃
+	for i := 0; i < 3; i++ {
+		if _, err := q.promoteAndFetch(ctx, poison); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.promoteAndFetch(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := make(chan string, 2)
+	q.Handle("job", func(ctx context.Context, job *Job) error {
+		ran <- job.ID
+		return nil
+	})
+	q.Handle("poison", func(ctx context.Context, job *Job) error {
+		ran <- job.ID
+		return nil
+	})
+	start(t, q)
+
+	select {
+	case got := <-ran:
+		if got != id {
+			t.Fatalf("poison job was executed")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not requeued after visibility timeout")
+	}
+	eventually(t, func() bool {
+		dead, _ := q.Dead(ctx, 10)
+		return len(dead) == 1 && dead[0].ID == poison
+	})
+}
+
+// promoteAndFetch 等待任务可以被拉取后取出 不执行也不确认
+func (q *Queue) promoteAndFetch(ctx context.Context, id string) (*Job, error) {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		keys := []string{q.key("scheduled"), q.key("ready"), q.key("processing")}
+		if err := promoteScript.Run(ctx, q.client, keys, time.Now().UnixMilli(), 1000).Err(); err != nil {
+			return nil, err
+		}
+		// 就绪队列中可能有其他任务 把它们放回去
+		var others []*Job
+		for {
+			job, err := q.fetch(ctx)
+			if err != nil || job == nil {
+				break
+			}
+			if job.ID == id {
+				for _, o := range others {
+					q.requeue(ctx, o)
+				}
+				return job, nil
+			}
+			others = append(others, job)
+		}
+		for _, o := range others {
+			q.requeue(ctx, o)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, errors.New("job not fetched")
+}
+
+func (q *Queue) requeue(ctx context.Context, job *Job) {
+	keys := []string{q.key("processing"), q.key("ready"), q.key("attempts")}
+	_ = requeueScript.Run(ctx, q.client, keys, job.ID).Err()
+}
+
+func TestStopContextRequeuesCancelledJobs(t *testing.T) {
+	q, mr := newQueue(t)
+	started := make(chan struct{})
+	q.Handle("slow", func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := q.Enqueue(context.Background(), "slow", []byte(`{}`))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.StopContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("StopContext = %v", err)
+	}
+
+	ready, _ := mr.List(q.key("ready"))
+	if len(ready) != 1 || ready[0] != id {
+		t.Errorf("ready = %v", ready)
+	}
+	if attempts := mr.HGet(q.key("attempts"), id); attempts != "0" {
+		t.Errorf("attempts = %q", attempts)
+	}
+	if dead, _ := q.Dead(context.Background(), 10); len(dead) != 0 {
+		t.Errorf("dead = %v", dead)
+	}
+}
+
+func TestStopContextDrains(t *testing.T) {
+	q, mr := newQueue(t)
+	var finished atomic.Bool
+	started := make(chan struct{})
+	q.Handle("slow", func(ctx context.Context, job *Job) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = q.Enqueue(context.Background(), "slow", []byte(`{}`))
+	<-started
+
+	if err := q.StopContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Load() || mr.Exists(q.key("jobs")) {
+		t.Error("running job was not drained and acknowledged")
+	}
+}
@@ -0,0 +1,81 @@
+package queue
+
+import "github.com/redis/go-redis/v9"
+
+// 队列使用的redis结构 key均以 前缀+队列名称: 开头
+//   jobs       hash 任务id -> 任务json
+//   attempts   hash 任务id -> 已执行次数
+//   ready      list 可以执行的任务id
+//   scheduled  zset 延迟执行和等待重试的任务id 分数为执行时间
+//   processing zset 正在执行的任务id 分数为可见性超时的时间
+//   dead       list 超过重试次数的任务json
+
+// promoteScript 把到期的延迟任务和可见性超时的任务移动到就绪队列
+// KEYS: scheduled ready processing ARGV: 当前时间 单次最多移动的数量
+var promoteScript = redis.NewScript(`
+local moved = 0
+for _, key in ipairs({KEYS[1], KEYS[3]}) do
+	local ids = redis.call('ZRANGEBYSCORE', key, '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+	for _, id in ipairs(ids) do
+		redis.call('ZREM', key, id)
+		redis.call('LPUSH', KEYS[2], id)
+		moved = moved + 1
+	end
+end
+return moved
+`)
+
+// fetchScript 取出一个就绪的任务放入处理中队列 并增加执行次数
+// KEYS: ready processing jobs attempts ARGV: 可见性超时的时间
+var fetchScript = redis.NewScript(`
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+local data = redis.call('HGET', KEYS[3], id)
+if not data then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[1], id)
+local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+return {data, tostring(attempts)}
+`)
+
+// ackScript 任务执行成功 删除任务 任务已经因为可见性超时重新入队时不处理
+// KEYS: processing jobs attempts ARGV: 任务id
+var ackScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1
+`)
+
+// requeueScript 停止服务时取消的任务立即放回就绪队列的队首 并撤销本次的执行次数
+// KEYS: processing ready attempts ARGV: 任务id
+var requeueScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HINCRBY', KEYS[3], ARGV[1], -1)
+redis.call('RPUSH', KEYS[2], ARGV[1])
+return 1
+`)
+
+// failScript 任务执行失败 重试时间为空时进入死信队列 否则等待重试
+// KEYS: processing scheduled jobs attempts dead ARGV: 任务id 任务json 重试时间
+var failScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[3] == '' then
+	redis.call('HDEL', KEYS[3], ARGV[1])
+	redis.call('HDEL', KEYS[4], ARGV[1])
+	redis.call('LPUSH', KEYS[5], ARGV[2])
+else
+	redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+end
+return 1
+`)
@@ -0,0 +1,24 @@
+// Package requestid 在ctx中传递请求id 不依赖gin 供http客户端等底层包使用
+// http服务中使用 web.RequestID 中间件生成请求id
+package requestid
+
+import "context"
+
+// Header 传递请求id的请求头
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// NewContext 把请求id保存到ctx中
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 获取ctx中的请求id 没有时返回空
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
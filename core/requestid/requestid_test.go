@@ -0,0 +1,18 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if id := FromContext(nil); id != "" {
+		t.Errorf("nil ctx = %q", id)
+	}
+	if id := FromContext(context.Background()); id != "" {
+		t.Errorf("empty ctx = %q", id)
+	}
+	if id := FromContext(NewContext(context.Background(), "req-1")); id != "req-1" {
+		t.Errorf("id = %q", id)
+	}
+}
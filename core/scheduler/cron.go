@@ -0,0 +1,319 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算下一次执行的时间
+type Schedule interface {
+	// Next 返回t之后下一次执行的时间 没有下一次时返回零值
+	Next(t time.Time) time.Time
+}
+
+// bounds 字段的取值范围
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	seconds = bounds{0, 59, nil}
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	dom     = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dow = bounds{0, 6, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// starBit 字段为*或?时设置 用于日和星期的匹配规则
+const starBit = 1 << 63
+
+// CronSchedule cron表达式 每个字段使用位图表示可以执行的值
+type CronSchedule struct {
+	Second, Minute, Hour, Dom, Month, Dow uint64
+	Location                              *time.Location
+}
+
+// EverySchedule 固定间隔执行
+type EverySchedule struct {
+	Every time.Duration
+}
+
+// Next 实现 Schedule 按间隔对齐到秒
+func (s EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Every - time.Duration(t.Nanosecond()))
+}
+
+// ParseCron 解析cron表达式
+// 支持6个字段 秒 分 时 日 月 星期 或省略秒的5个字段
+// 支持 * ? , - / 以及月份和星期的英文缩写 支持 @yearly @monthly @weekly @daily @hourly @every 1m30s
+// 表达式可以使用 CRON_TZ=Asia/Shanghai 前缀指定时区 否则使用 loc
+func ParseCron(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if loc == nil {
+		loc = time.Local
+	}
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.Index(spec, " ")
+		if i < 0 {
+			return nil, fmt.Errorf("cron表达式错误: %s", spec)
+		}
+		name := spec[strings.Index(spec, "=")+1 : i]
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("cron表达式时区错误 %s: %w", name, err)
+		}
+		spec = strings.TrimSpace(spec[i:])
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		return parseDescriptor(spec, loc)
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron表达式需要5或6个字段: %s", spec)
+	}
+
+	s := &CronSchedule{Location: loc}
+	var err error
+	for i, f := range []struct {
+		field *uint64
+		b     bounds
+	}{
+		{&s.Second, seconds}, {&s.Minute, minutes}, {&s.Hour, hours},
+		{&s.Dom, dom}, {&s.Month, months}, {&s.Dow, dow},
+	} {
+		if *f.field, err = parseField(fields[i], f.b); err != nil {
+			return nil, fmt.Errorf("cron表达式错误 %s: %w", spec, err)
+		}
+	}
+	return s, nil
+}
+
+func parseDescriptor(spec string, loc *time.Location) (Schedule, error) {
+	all := func(b bounds) uint64 { return bits(b.min, b.max, 1) | starBit }
+	switch spec {
+	case "@yearly", "@annually":
+		return &CronSchedule{1, 1, 1, 1 << dom.min, 1 << months.min, all(dow), loc}, nil
+	case "@monthly":
+		return &CronSchedule{1, 1, 1, 1 << dom.min, all(months), all(dow), loc}, nil
+	case "@weekly":
+		return &CronSchedule{1, 1, 1, all(dom), all(months), 1, loc}, nil
+	case "@daily", "@midnight":
+		return &CronSchedule{1, 1, 1, all(dom), all(months), all(dow), loc}, nil
+	case "@hourly":
+		return &CronSchedule{1, 1, all(hours), all(dom), all(months), all(dow), loc}, nil
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("cron表达式错误 %s: %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron表达式错误 %s: 间隔不能小于1秒", spec)
+		}
+		return EverySchedule{Every: d.Truncate(time.Second)}, nil
+	}
+	return nil, fmt.Errorf("不支持的cron表达式: %s", spec)
+}
+
+// parseField 解析逗号分隔的字段
+func parseField(field string, b bounds) (uint64, error) {
+	var result uint64
+	for _, expr := range strings.Split(field, ",") {
+		bit, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		result |= bit
+	}
+	return result, nil
+}
+
+// parseRange 解析 * ? n n-m 以及 /step
+func parseRange(expr string, b bounds) (uint64, error) {
+	var (
+		start, end, step uint = 0, 0, 1
+		extra            uint64
+		err              error
+	)
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("字段错误: %s", expr)
+		}
+		start, end = b.min, b.max
+		extra = starBit
+	} else {
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		switch len(lowAndHigh) {
+		case 1:
+			end = start
+		case 2:
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("字段错误: %s", expr)
+		}
+	}
+
+	switch len(rangeAndStep) {
+	case 1:
+	case 2:
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 0)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("步长错误: %s", expr)
+		}
+		step = uint(n)
+		// n/step 表示从n开始到最大值
+		if len(lowAndHigh) == 1 && extra == 0 {
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	default:
+		return 0, fmt.Errorf("字段错误: %s", expr)
+	}
+
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("字段超出范围 %d-%d: %s", b.min, b.max, expr)
+	}
+	return bits(start, end, step) | extra, nil
+}
+
+func parseValue(expr string, b bounds) (uint, error) {
+	if b.names != nil {
+		if v, ok := b.names[strings.ToLower(expr)]; ok {
+			return v, nil
+		}
+	}
+	n, err := strconv.ParseUint(expr, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("字段值错误: %s", expr)
+	}
+	// 星期支持7表示周日
+	if b.max == dow.max && n == 7 {
+		n = 0
+	}
+	return uint(n), nil
+}
+
+// bits 设置 min 到 max 之间每隔 step 的位
+func bits(min, max, step uint) uint64 {
+	var bits uint64
+	for i := min; i <= max; i += step {
+		bits |= 1 << i
+	}
+	return bits
+}
+
+// Next 实现 Schedule 逐个字段查找下一个匹配的时间
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	origLocation := t.Location()
+	t = t.In(s.Location)
+
+	// 从下一秒开始
+	t = t.Add(1*time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
+	added := false
+	// 最多查找5年
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.Month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.Location)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.Location)
+		}
+		t = t.AddDate(0, 0, 1)
+		// 夏令时可能导致时间不是0点
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Hour())&s.Hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.Location)
+		}
+		t = t.Add(1 * time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.Minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(1 * time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Second())&s.Second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(1 * time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origLocation)
+}
+
+// dayMatches 日和星期都不是*时 满足其中一个即可 否则两个都要满足
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&s.Dom > 0
+	dowMatch := 1<<uint(t.Weekday())&s.Dow > 0
+	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
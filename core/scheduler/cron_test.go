@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	from := time.Date(2024, 1, 31, 23, 59, 58, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * * *", time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 30 9 * * mon-fri", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 0 29 feb ?", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 12 1 * 7", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 1m", time.Date(2024, 2, 1, 0, 0, 58, 0, time.UTC)},
+		{"CRON_TZ=Asia/Shanghai 0 0 8 * * *", time.Date(2024, 2, 1, 8, 0, 0, 0, shanghai)},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.spec, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v; want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronError(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * * *", "* * * * * 8", "*/0 * * * * *", "@every 1ms", "CRON_TZ=Nowhere/City * * * * *"} {
+		if _, err := ParseCron(spec, time.UTC); err == nil {
+			t.Errorf("%s: 应该解析失败", spec)
+		}
+	}
+}
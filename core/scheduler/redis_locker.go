@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultLockPrefix 集群锁key的默认前缀
+const DefaultLockPrefix = "scheduler:lock:"
+
+// RedisLocker 使用redis实现的集群锁 每次调度使用任务名称和调度时间作为key
+type RedisLocker struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{
+		Client: client,
+		Prefix: DefaultLockPrefix,
+	}
+}
+
+// TryLock 实现 Locker 使用 SET NX 获取锁
+func (r *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.Client.SetNX(ctx, r.Prefix+key, 1, ttl).Result()
+}
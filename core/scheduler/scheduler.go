@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/lshaofan/cb-framework/server"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrJobExists 任务名称重复
+	ErrJobExists = errors.New("任务已存在")
+)
+
+// JobFunc 任务函数 ctx 在调度器停止或任务超时时取消
+type JobFunc func(ctx context.Context) error
+
+// Locker 集群锁 多个实例中只有获取到锁的实例执行同一次调度
+// 锁的key为 任务名称:调度时间 只保证同一次调度不重复执行 不会在任务执行期间持有
+type Locker interface {
+	// TryLock 尝试获取锁 获取成功返回true 锁在ttl之后自动释放
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type Option func(*Scheduler)
+
+// WithLocation 设置默认时区 表达式中没有指定 CRON_TZ 时使用 默认为本地时区
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) {
+		s.location = loc
+	}
+}
+
+// WithLocker 设置集群锁 设置后多个实例中每次调度只有一个实例执行
+// 集群锁只对同一次调度去重 防止执行重叠只在单个实例内生效 见 WithOverlap
+// 任务执行时间超过调度间隔时 下一次调度可能由其他实例执行 与本实例未结束的执行重叠
+// 需要在集群中互斥的任务应该在任务函数内自行加锁
+func WithLocker(locker Locker) Option {
+	return func(s *Scheduler) {
+		s.locker = locker
+	}
+}
+
+// WithLockTTL 设置集群锁的过期时间 需要大于实例之间的时钟误差 默认1分钟
+func WithLockTTL(ttl time.Duration) Option {
+	return func(s *Scheduler) {
+		s.lockTTL = ttl
+	}
+}
+
+// WithLogger 设置日志 默认使用logrus的标准日志
+func WithLogger(l logrus.FieldLogger) Option {
+	return func(s *Scheduler) {
+		s.logger = l
+	}
+}
+
+type JobOption func(*job)
+
+// WithTimeout 设置任务的超时时间 超时后取消任务的ctx
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(j *job) {
+		j.timeout = timeout
+	}
+}
+
+// WithOverlap 允许同一个任务的多次执行重叠 默认上一次执行未结束时跳过本次执行
+// 跳过只检查当前实例中的执行 不检查集群中的其他实例
+func WithOverlap() JobOption {
+	return func(j *job) {
+		j.overlap = true
+	}
+}
+
+// WithLocalOnly 任务不使用集群锁 每个实例都执行
+func WithLocalOnly() JobOption {
+	return func(j *job) {
+		j.localOnly = true
+	}
+}
+
+type job struct {
+	name      string
+	schedule  Schedule
+	fn        JobFunc
+	timeout   time.Duration
+	overlap   bool
+	localOnly bool
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Scheduler 定时任务调度器 实现 server.Service
+type Scheduler struct {
+	location *time.Location
+	locker   Locker
+	lockTTL  time.Duration
+	logger   logrus.FieldLogger
+
+	mu        sync.Mutex
+	jobs      []*job
+	ctx       context.Context // 调度循环的ctx
+	cancel    context.CancelFunc
+	runCtx    context.Context // 正在执行的任务的ctx
+	runCancel context.CancelFunc
+	loops     sync.WaitGroup
+	runs      sync.WaitGroup
+}
+
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{
+		location: time.Local,
+		lockTTL:  time.Minute,
+		logger:   logrus.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// AddFunc 添加任务 spec 为cron表达式 见 ParseCron 任务名称在集群中需要唯一
+func (s *Scheduler) AddFunc(name, spec string, fn JobFunc, opts ...JobOption) error {
+	schedule, err := ParseCron(spec, s.location)
+	if err != nil {
+		return err
+	}
+	return s.Add(name, schedule, fn, opts...)
+}
+
+// Add 使用自定义的 Schedule 添加任务
+func (s *Scheduler) Add(name string, schedule Schedule, fn JobFunc, opts ...JobOption) error {
+	j := &job{name: name, schedule: schedule, fn: fn}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, old := range s.jobs {
+		if old.name == name {
+			return fmt.Errorf("%w: %s", ErrJobExists, name)
+		}
+	}
+	s.jobs = append(s.jobs, j)
+	// 调度器已经启动时直接开始调度
+	if s.ctx != nil {
+		s.startJob(j)
+	}
+	return nil
+}
+
+// Name 实现 server.Namer
+func (s *Scheduler) Name() string {
+	return "scheduler"
+}
+
+// Init 实现 server.Service
+func (s *Scheduler) Init(server.Environment) error {
+	return nil
+}
+
+// Start 实现 server.Service 开始调度所有任务
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.runCtx, s.runCancel = context.WithCancel(context.Background())
+	for _, j := range s.jobs {
+		s.startJob(j)
+	}
+	return nil
+}
+
+// Stop 实现 server.Service 停止调度并等待正在执行的任务结束
+func (s *Scheduler) Stop() error {
+	return s.StopContext(context.Background())
+}
+
+// StopContext 实现 server.ContextStopper 停止调度并等待正在执行的任务结束 ctx 结束时取消正在执行的任务
+func (s *Scheduler) StopContext(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, runCancel := s.cancel, s.runCancel
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	// 先停止调度 再等待正在执行的任务
+	cancel()
+	s.loops.Wait()
+	defer runCancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startJob 开始任务的调度循环 调用时需要持有 s.mu
+func (s *Scheduler) startJob(j *job) {
+	s.loops.Add(1)
+	go s.loop(s.ctx, s.runCtx, j)
+}
+
+// loop 按任务的 Schedule 循环调度 ctx 取消时退出 runCtx 传给执行的任务
+func (s *Scheduler) loop(ctx, runCtx context.Context, j *job) {
+	defer s.loops.Done()
+	now := time.Now()
+	for {
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			s.fire(runCtx, j, next)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		now = next
+	}
+}
+
+// fire 执行一次调度
+func (s *Scheduler) fire(ctx context.Context, j *job, at time.Time) {
+	if !j.overlap {
+		j.mu.Lock()
+		if j.running {
+			j.mu.Unlock()
+			s.logger.WithField("job", j.name).Warn("scheduler: 上一次执行未结束 跳过本次执行")
+			return
+		}
+		j.running = true
+		j.mu.Unlock()
+	}
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer func() {
+			if !j.overlap {
+				j.mu.Lock()
+				j.running = false
+				j.mu.Unlock()
+			}
+		}()
+		if s.locker != nil && !j.localOnly {
+			key := fmt.Sprintf("%s:%d", j.name, at.Unix())
+			ok, err := s.locker.TryLock(ctx, key, s.lockTTL)
+			if err != nil {
+				s.logger.WithError(err).WithField("job", j.name).Error("scheduler: 获取集群锁失败")
+				return
+			}
+			if !ok {
+				return
+			}
+		}
+		s.run(ctx, j)
+	}()
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	logger := s.logger.WithField("job", j.name)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithField("stack", string(debug.Stack())).Errorf("scheduler: 任务panic: %v", r)
+		}
+	}()
+	if err := j.fn(ctx); err != nil {
+		logger.WithError(err).WithField("latency", time.Since(start).String()).Error("scheduler: 任务执行失败")
+		return
+	}
+	logger.WithField("latency", time.Since(start).String()).Debug("scheduler: 任务执行成功")
+}
@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// every 按固定间隔对齐调度 多个实例得到相同的调度时间
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// syncBuffer 并发安全的日志输出
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newScheduler(opts ...Option) (*Scheduler, *syncBuffer) {
+	out := &syncBuffer{}
+	l := logrus.New()
+	l.SetOutput(out)
+	l.SetLevel(logrus.DebugLevel)
+	return New(append([]Option{WithLogger(l)}, opts...)...), out
+}
+
+// waitFor 等待条件成立 超时后失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerStartStop(t *testing.T) {
+	s, _ := newScheduler()
+	var runs atomic.Int32
+	count := func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+	if err := s.Add("count", every(5*time.Millisecond), count); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("count", every(time.Hour), count); !errors.Is(err, ErrJobExists) {
+		t.Errorf("duplicate job = %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "two runs", func() bool { return runs.Load() >= 2 })
+
+	// 启动后添加的任务直接开始调度
+	var late atomic.Bool
+	if err := s.Add("late", every(5*time.Millisecond), func(ctx context.Context) error {
+		late.Store(true)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "late job", late.Load)
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Error("job ran after Stop")
+	}
+}
+
+func TestSchedulerSkipOverlap(t *testing.T) {
+	for _, overlap := range []bool{false, true} {
+		s, out := newScheduler()
+		release := make(chan struct{})
+		var runs atomic.Int32
+		var opts []JobOption
+		if overlap {
+			opts = append(opts, WithOverlap())
+		}
+		if err := s.Add("slow", every(5*time.Millisecond), func(ctx context.Context) error {
+			runs.Add(1)
+			<-release
+			return nil
+		}, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "first run", func() bool { return runs.Load() >= 1 })
+		time.Sleep(30 * time.Millisecond)
+		// 在第一次执行结束前检查
+		got := runs.Load()
+		close(release)
+		if err := s.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		if overlap && got < 2 {
+			t.Errorf("overlap: runs = %d", got)
+		}
+		if !overlap && (got != 1 || !strings.Contains(out.String(), "跳过本次执行")) {
+			t.Errorf("skip: runs = %d; log = %s", got, out)
+		}
+	}
+}
+
+func TestSchedulerTimeout(t *testing.T) {
+	s, out := newScheduler()
+	errs := make(chan error, 1)
+	if err := s.Add("timeout", every(time.Hour/2), func(ctx context.Context) error {
+		<-ctx.Done()
+		select {
+		case errs <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	}, WithTimeout(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	// 直接执行一次调度 不等待调度时间
+	s.fire(context.Background(), s.jobs[0], time.Now())
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ctx err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job was not cancelled")
+	}
+	s.runs.Wait()
+	if !strings.Contains(out.String(), "任务执行失败") {
+		t.Errorf("log = %s", out)
+	}
+}
+
+func TestSchedulerPanic(t *testing.T) {
+	s, out := newScheduler()
+	var runs atomic.Int32
+	if err := s.Add("panic", every(5*time.Millisecond), func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// panic之后任务继续调度
+	waitFor(t, "run after panic", func() bool { return runs.Load() >= 2 })
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if log := out.String(); !strings.Contains(log, "任务panic: boom") || !strings.Contains(log, "stack") {
+		t.Errorf("log = %s", log)
+	}
+}
+
+func TestSchedulerStopContext(t *testing.T) {
+	s, _ := newScheduler()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	if err := s.Add("block", every(5*time.Millisecond), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.StopContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("stop = %v", err)
+	}
+	// 等待超时后取消正在执行的任务
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("running job was not cancelled")
+	}
+}
+
+type failingLocker struct{}
+
+func (failingLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("locker down")
+}
+
+func TestSchedulerLocker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	var runs, local atomic.Int32
+	var schedulers []*Scheduler
+	for i := 0; i < 2; i++ {
+		s, _ := newScheduler(WithLocker(NewRedisLocker(client)), WithLockTTL(time.Minute))
+		// 调度时间按秒对齐 两个实例得到相同的key
+		if err := s.Add("cluster", every(time.Second), func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add("local", every(time.Second), func(ctx context.Context) error {
+			local.Add(1)
+			return nil
+		}, WithLocalOnly()); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Stop()
+		schedulers = append(schedulers, s)
+	}
+
+	waitFor(t, "local runs", func() bool { return local.Load() >= 2 })
+	for _, s := range schedulers {
+		if err := s.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 每次调度只有一个实例执行 不使用集群锁的任务每个实例都执行
+	if fired := local.Load() / 2; runs.Load() != fired {
+		t.Errorf("cluster runs = %d; want %d", runs.Load(), fired)
+	}
+	keys := mr.Keys()
+	if len(keys) == 0 || !strings.HasPrefix(keys[0], DefaultLockPrefix+"cluster:") {
+		t.Errorf("keys = %v", keys)
+	}
+
+	// 获取锁失败时不执行任务
+	s, out := newScheduler(WithLocker(failingLocker{}))
+	var called atomic.Bool
+	if err := s.Add("fail", every(time.Hour), func(ctx context.Context) error {
+		called.Store(true)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.fire(context.Background(), s.jobs[0], time.Now())
+	s.runs.Wait()
+	if called.Load() || !strings.Contains(out.String(), "获取集群锁失败") {
+		t.Errorf("called = %v; log = %s", called.Load(), out)
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	l := NewRedisLocker(client)
+
+	if ok, err := l.TryLock(ctx, "job:1", time.Minute); !ok || err != nil {
+		t.Fatalf("first lock = %v, %v", ok, err)
+	}
+	if ok, err := l.TryLock(ctx, "job:1", time.Minute); ok || err != nil {
+		t.Errorf("second lock = %v, %v", ok, err)
+	}
+	if ok, _ := l.TryLock(ctx, "job:2", time.Minute); !ok {
+		t.Error("different key was locked")
+	}
+	if ttl := mr.TTL(DefaultLockPrefix + "job:1"); ttl != time.Minute {
+		t.Errorf("ttl = %v", ttl)
+	}
+
+	// 锁在ttl之后自动释放
+	mr.FastForward(time.Minute)
+	if ok, err := l.TryLock(ctx, "job:1", time.Minute); !ok || err != nil {
+		t.Errorf("lock after ttl = %v, %v", ok, err)
+	}
+
+	mr.Close()
+	if _, err := l.TryLock(ctx, "job:3", time.Minute); err == nil {
+		t.Error("lock succeeded without redis")
+	}
+}
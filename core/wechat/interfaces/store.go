@@ -1,5 +1,7 @@
 package interfaces
 
+import "context"
+
 type Store interface {
 	// GetAccessToken 获取AccessToken
 	GetAccessToken(key string) (string, error)
@@ -9,3 +11,8 @@ type Store interface {
 	GetJsapiTicket(key string) (string, error)
 	SetJsapiTicket(key string, jsapiTicket string, expires int64) error
 }
+
+// HealthChecker 健康检查 Store 实现此接口时 Client 的健康检查会先检查 Store
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
@@ -0,0 +1,115 @@
+package miniprogram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// healthStore 测试使用的 Store 记录读取的key
+type healthStore struct {
+	token   string
+	err     error
+	ping    error
+	block   chan struct{}
+	getKeys []string
+}
+
+func (s *healthStore) GetAccessToken(key string) (string, error) {
+	s.getKeys = append(s.getKeys, key)
+	if s.block != nil {
+		<-s.block
+	}
+	return s.token, s.err
+}
+
+func (s *healthStore) SetAccessToken(key string, accessToken string, expires int64) error {
+	return errors.New("unexpected SetAccessToken")
+}
+
+func (s *healthStore) GetJsapiTicket(key string) (string, error) {
+	return "", redis.Nil
+}
+
+func (s *healthStore) SetJsapiTicket(key string, jsapiTicket string, expires int64) error {
+	return nil
+}
+
+func (s *healthStore) HealthCheck(ctx context.Context) error {
+	return s.ping
+}
+
+// failHttpClient 健康检查不应请求微信接口
+type failHttpClient struct {
+	t *testing.T
+}
+
+func (f failHttpClient) Get(uri string) ([]byte, error) {
+	f.t.Errorf("unexpected request %s", uri)
+	return nil, errors.New("unexpected request")
+}
+
+func (f failHttpClient) Post(uri string, data []byte, header map[string]string) ([]byte, error) {
+	return f.Get(uri)
+}
+
+func (f failHttpClient) PostJSON(uri string, params interface{}) ([]byte, error) {
+	return f.Get(uri)
+}
+
+func TestHealthCheck(t *testing.T) {
+	storeErr := errors.New("store down")
+	tests := []struct {
+		name  string
+		store *healthStore
+		want  error
+	}{
+		{"cached token", &healthStore{token: "token"}, nil},
+		// 缓存过期时不请求微信接口
+		{"token expired", &healthStore{err: redis.Nil}, nil},
+		{"read error", &healthStore{err: storeErr}, storeErr},
+		{"ping error", &healthStore{ping: storeErr}, storeErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithHttpClient(failHttpClient{t}), WithAppidAndSecret("appid", "secret"))
+			client.Store = tt.store
+			if err := client.HealthCheck(context.Background()); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v; want %v", err, tt.want)
+			}
+		})
+	}
+
+	store := &healthStore{}
+	client := NewClient(WithHttpClient(failHttpClient{t}), WithAccessToken("token"))
+	client.Store = store
+	if err := client.HealthCheck(context.Background()); err != nil || len(store.getKeys) != 0 {
+		t.Errorf("static token err = %v; keys = %v", err, store.getKeys)
+	}
+}
+
+func TestHealthCheckDeadline(t *testing.T) {
+	store := &healthStore{block: make(chan struct{})}
+	defer close(store.block)
+	client := NewClient(WithHttpClient(failHttpClient{t}), WithAppidAndSecret("appid", "secret"))
+	client.Store = store
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := client.HealthCheck(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("health check took %v", elapsed)
+	}
+
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if err := client.HealthCheck(done); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled err = %v", err)
+	}
+}
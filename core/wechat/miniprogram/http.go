@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/lshaofan/cb-framework/core/requestid"
 	"github.com/lshaofan/cb-framework/server/web"
 	"io"
 	"net/http"
@@ -37,7 +38,12 @@ func (h *HttpClient) PostJSON(uri string, params interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	response, err := http.Post(uri, "application/json;charset=utf-8", jsonBuf)
+	request, err := h.newRequest(http.MethodPost, uri, jsonBuf)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json;charset=utf-8")
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +62,7 @@ func (h *HttpClient) PostJSON(uri string, params interface{}) ([]byte, error) {
 
 func (h *HttpClient) Post(uri string, data []byte, header map[string]string) ([]byte, error) {
 	body := bytes.NewBuffer(data)
-	request, err := http.NewRequestWithContext(h.ctx, http.MethodPost, uri, body)
+	request, err := h.newRequest(http.MethodPost, uri, body)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +94,7 @@ func (h *HttpClient) Post(uri string, data []byte, header map[string]string) ([]
 }
 
 func (h *HttpClient) Get(uri string) ([]byte, error) {
-	request, err := http.NewRequestWithContext(h.ctx, http.MethodGet, uri, nil)
+	request, err := h.newRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +120,25 @@ func (h *HttpClient) Get(uri string) ([]byte, error) {
 	return io.ReadAll(res.Body)
 }
 
+// WithContext 返回使用ctx发送请求的客户端 ctx 中有请求id时会通过 X-Request-ID 请求头传递
+func (h *HttpClient) WithContext(ctx context.Context) *HttpClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
+// newRequest 创建使用客户端ctx的请求 并带上ctx中的请求id
+func (h *HttpClient) newRequest(method, uri string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(h.ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if id := requestid.FromContext(h.ctx); id != "" {
+		request.Header.Set(requestid.Header, id)
+	}
+	return request, nil
+}
+
 func NewHttpClient(opts ...HttpCliOptions) *HttpClient {
 	h := new(HttpClient)
 	for _, o := range opts {
@@ -0,0 +1,42 @@
+package miniprogram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lshaofan/cb-framework/core/requestid"
+)
+
+func TestHttpClientRequestID(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(requestid.Header)
+		_, _ = w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(WithAccessToken("token"))
+	if _, err := client.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id := <-got; id != "" {
+		t.Errorf("request id without ctx = %q", id)
+	}
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	if _, err := client.WithContext(ctx).Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id := <-got; id != "req-1" {
+		t.Errorf("request id = %q", id)
+	}
+
+	// 请求的ctx取消时对微信接口的请求也取消
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.WithContext(cancelled).Get(srv.URL, nil); err == nil {
+		t.Error("cancelled ctx did not cancel the request")
+	}
+}
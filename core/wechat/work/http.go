@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/lshaofan/cb-framework/core/requestid"
 	"github.com/lshaofan/cb-framework/server/web"
+	"github.com/sirupsen/logrus"
 	"io"
 	"net/http"
 )
@@ -44,7 +46,12 @@ func (h *HttpClient) PostJSON(uri string, params interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	response, err := http.Post(uri, "application/json;charset=utf-8", jsonBuf)
+	request, err := h.newRequest(http.MethodPost, uri, jsonBuf)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json;charset=utf-8")
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +70,7 @@ func (h *HttpClient) PostJSON(uri string, params interface{}) ([]byte, error) {
 
 func (h *HttpClient) Post(uri string, data []byte, header map[string]string) ([]byte, error) {
 	body := bytes.NewBuffer(data)
-	request, err := http.NewRequestWithContext(h.ctx, http.MethodPost, uri, body)
+	request, err := h.newRequest(http.MethodPost, uri, body)
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +102,10 @@ func (h *HttpClient) Post(uri string, data []byte, header map[string]string) ([]
 }
 
 func (h *HttpClient) Get(uri string) ([]byte, error) {
-	// debug
-	// debug
 	if h.debug {
-		fmt.Println("get请求的url:", uri)
+		logrus.WithFields(logrus.Fields{"url": uri, "request_id": requestid.FromContext(h.ctx)}).Info("wechat: get请求")
 	}
-	request, err := http.NewRequestWithContext(h.ctx, http.MethodGet, uri, nil)
+	request, err := h.newRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -124,13 +129,31 @@ func (h *HttpClient) Get(uri string) ([]byte, error) {
 		)
 	}
 	resp, err := io.ReadAll(res.Body)
-	// debug
 	if h.debug {
-		fmt.Println("get请求的resp:", string(resp))
+		logrus.WithFields(logrus.Fields{"url": uri, "resp": string(resp), "request_id": requestid.FromContext(h.ctx)}).Info("wechat: get请求的响应")
 	}
 	return resp, err
 }
 
+// WithContext 返回使用ctx发送请求的客户端 ctx 中有请求id时会通过 X-Request-ID 请求头传递
+func (h *HttpClient) WithContext(ctx context.Context) *HttpClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
+// newRequest 创建使用客户端ctx的请求 并带上ctx中的请求id
+func (h *HttpClient) newRequest(method, uri string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(h.ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if id := requestid.FromContext(h.ctx); id != "" {
+		request.Header.Set(requestid.Header, id)
+	}
+	return request, nil
+}
+
 func NewHttpClient(opts ...HttpCliOptions) *HttpClient {
 	h := new(HttpClient)
 	for _, o := range opts {
@@ -1,6 +1,7 @@
 package work
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -75,6 +76,9 @@ const (
 	ExternalContactAccessTokenType AccessTokenType = "external_contact_secret"
 )
 
+// Client 的方法没有ctx参数 发送请求使用创建 HttpClient 时的ctx 默认为 context.Background()
+// 在http请求中调用时需要先使用 WithContext 传入请求的ctx 例如 client.WithContext(c.Request.Context()).GetJsapiTicket()
+// 否则请求id不会传递给微信接口 请求取消时对微信接口的请求也不会取消
 type Client struct {
 	ctx               *gin.Context
 	CorpID            string `json:"corp_id"`
@@ -108,6 +112,17 @@ func NewClient(o ...Options) *Client {
 	return c
 }
 
+// WithContext 返回使用ctx请求微信接口的客户端副本 ctx 中的请求id会随请求传递 ctx 取消时请求也会取消
+// 副本和原客户端共用缓存和获取access_token的锁 可以在每个请求中调用
+// 自定义的 HttpClient 不是 *HttpClient 时不做处理 需要自定义的 HttpClient 自行传递ctx
+func (c *Client) WithContext(ctx context.Context) *Client {
+	cc := *c
+	if h, ok := c.HttpClient.(*HttpClient); ok {
+		cc.HttpClient = h.WithContext(ctx)
+	}
+	return &cc
+}
+
 // HasQuery 判断url中是否有参数
 func (c *Client) HasQuery(url string) bool {
 	return strings.Contains(url, "?")
@@ -376,3 +391,35 @@ func (c *Client) getAccessTokenFromServer() (*GetAccessTokenResult, error) {
 	}
 	return ret, nil
 }
+
+// HealthCheck 健康检查 实现 health.HealthChecker 只检查 Store 和缓存中的access_token能否读取
+// 不会请求微信接口获取access_token 避免探针消耗接口的调用次数 缓存中没有access_token时也视为正常
+// ctx 结束时立即返回 ctx.Err()
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if h, ok := c.Store.(interfaces.HealthChecker); ok {
+		if err := h.HealthCheck(ctx); err != nil {
+			return err
+		}
+	}
+	if c.AccessToken != "" || c.Store == nil {
+		return nil
+	}
+	// Store 的方法没有ctx参数 在后台读取 ctx 结束时不再等待
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.Store.GetAccessToken(fmt.Sprintf("%s%s", c.GetCachePrefix(), c.CorpID))
+		if errors.Is(err, redis.Nil) {
+			err = nil
+		}
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -37,3 +37,8 @@ func NewRedisStore(client *redis.Client) *RedisStore {
 		ctx:    context.Background(),
 	}
 }
+
+// HealthCheck ping redis 实现 health.HealthChecker
+func (r *RedisStore) HealthCheck(ctx context.Context) error {
+	return r.Client.Ping(ctx).Err()
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Group is a Service made of several member Services.
+//
+// Members are initialized and started in the order they were added and
+// stopped in reverse order. If a member fails to start, the members that
+// were already started are stopped again and the error is returned.
+// If a member implementing Fataler reports an error, the context of the
+// group is cancelled so that Run stops every member.
+type Group struct {
+	services []Service
+	started  []Service
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	fatal  chan error
+
+	mu  sync.Mutex
+	err error
+	wg  sync.WaitGroup
+}
+
+// NewGroup creates a Group from services.
+func NewGroup(services ...Service) *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{
+		services: services,
+		ctx:      ctx,
+		cancel:   cancel,
+		fatal:    make(chan error, 1),
+	}
+}
+
+// RunGroup runs services as a single Group. See Run.
+func RunGroup(services ...Service) error {
+	return Run(NewGroup(services...))
+}
+
+// Add appends services to the group. It must be called before Init.
+func (g *Group) Add(services ...Service) *Group {
+	g.services = append(g.services, services...)
+	return g
+}
+
+// Init calls Init on every member in declaration order. When a member
+// fails to initialize, the members already initialized are stopped in
+// reverse order so that they release what Init acquired, such as listeners.
+func (g *Group) Init(env Environment) error {
+	for i, s := range g.services {
+		if err := s.Init(env); err != nil {
+			err = fmt.Errorf("%s: init: %w", serviceName(s), err)
+			return errors.Join(err, stopServices(context.Background(), g.services[:i]))
+		}
+	}
+	return nil
+}
+
+// Start calls Start on every member in declaration order. When a member
+// fails to start, the members already started are stopped in reverse order.
+func (g *Group) Start() error {
+	for _, s := range g.services {
+		if err := s.Start(); err != nil {
+			err = fmt.Errorf("%s: start: %w", serviceName(s), err)
+			return errors.Join(err, g.stopStarted(context.Background()))
+		}
+		g.started = append(g.started, s)
+	}
+
+	for _, s := range g.started {
+		if f, ok := s.(Fataler); ok {
+			g.watchFatal(s, f.Fatal())
+		}
+		if c, ok := s.(Context); ok {
+			g.watchContext(c.Context())
+		}
+	}
+	return nil
+}
+
+// Stop calls Stop on every started member in reverse order.
+func (g *Group) Stop() error {
+	return g.StopContext(context.Background())
+}
+
+// StopContext stops every started member in reverse order. Members
+// implementing ContextStopper receive ctx. A member still running when ctx
+// is done is reported with a *StopTimeoutError carrying its name. Once ctx
+// is done, each remaining member is still stopped in turn and given a short
+// grace period, so the reverse order holds for the members that return.
+func (g *Group) StopContext(ctx context.Context) error {
+	g.cancel()
+	g.wg.Wait()
+	return g.stopStarted(ctx)
+}
+
+// Handle forwards the signal to the members implementing Handler.
+// ErrStop is returned when one of them returns it, or when a member does
+// not implement Handler, as Run would have stopped that member on its own.
+func (g *Group) Handle(sig os.Signal) error {
+	stop := false
+	for _, s := range g.started {
+		h, ok := s.(Handler)
+		if !ok || errors.Is(h.Handle(sig), ErrStop) {
+			stop = true
+		}
+	}
+	if stop {
+		return ErrStop
+	}
+	return nil
+}
+
+// Reload calls Reload on every started member implementing Reloader.
+// A failing member does not prevent the others from reloading.
+// ErrReloadUnsupported is returned when no member supports reloading.
+func (g *Group) Reload() error {
+	var errs []error
+	reloaded := false
+	for _, s := range g.started {
+		r, ok := s.(Reloader)
+		if !ok {
+			continue
+		}
+		err := r.Reload()
+		if errors.Is(err, ErrReloadUnsupported) {
+			continue
+		}
+		reloaded = true
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: reload: %w", serviceName(s), err))
+		}
+	}
+	if !reloaded {
+		return ErrReloadUnsupported
+	}
+	return errors.Join(errs...)
+}
+
+// Context returns a context which is done once a member reported a fatal
+// error or the context of a member is done.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Fatal implements Fataler so that groups can be nested.
+func (g *Group) Fatal() <-chan error {
+	return g.fatal
+}
+
+// Err returns the first fatal error reported by a member, if any.
+func (g *Group) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+func (g *Group) stopStarted(ctx context.Context) error {
+	err := stopServices(ctx, g.started)
+	g.started = nil
+	return err
+}
+
+// stopServices stops services one after the other in reverse order.
+func stopServices(ctx context.Context, services []Service) error {
+	var errs []error
+	for i := len(services) - 1; i >= 0; i-- {
+		s := services[i]
+		if err := stopContext(ctx, s, memberGracePeriod); err != nil {
+			errs = append(errs, fmt.Errorf("%s: stop: %w", serviceName(s), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (g *Group) watchFatal(s Service, ch <-chan error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		select {
+		case err, ok := <-ch:
+			if !ok || err == nil {
+				return
+			}
+			g.setErr(fmt.Errorf("%s: %w", serviceName(s), err))
+		case <-g.ctx.Done():
+		}
+	}()
+}
+
+func (g *Group) watchContext(ctx context.Context) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		select {
+		case <-ctx.Done():
+			g.cancel()
+		case <-g.ctx.Done():
+		}
+	}()
+}
+
+func (g *Group) setErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return
+	}
+	g.err = err
+	g.fatal <- err
+	g.cancel()
+}
+
+func serviceName(s Service) string {
+	if n, ok := s.(Namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", s)
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(e string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type fakeService struct {
+	name     string
+	rec      *recorder
+	initErr  error
+	startErr error
+	fatal    chan error
+}
+
+func (f *fakeService) Name() string { return f.name }
+
+func (f *fakeService) Init(Environment) error {
+	if f.initErr != nil {
+		return f.initErr
+	}
+	f.rec.add("init " + f.name)
+	return nil
+}
+
+func (f *fakeService) Start() error {
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.rec.add("start " + f.name)
+	return nil
+}
+
+func (f *fakeService) Stop() error {
+	f.rec.add("stop " + f.name)
+	return nil
+}
+
+func (f *fakeService) Fatal() <-chan error {
+	return f.fatal
+}
+
+func TestGroupOrder(t *testing.T) {
+	rec := &recorder{}
+	g := NewGroup(
+		&fakeService{name: "a", rec: rec},
+		&fakeService{name: "b", rec: rec},
+	).Add(&fakeService{name: "c", rec: rec})
+
+	if err := g.Init(environment{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"init a", "init b", "init c", "start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestGroupStartRollback(t *testing.T) {
+	rec := &recorder{}
+	startErr := errors.New("boom")
+	g := NewGroup(
+		&fakeService{name: "a", rec: rec},
+		&fakeService{name: "b", rec: rec},
+		&fakeService{name: "c", rec: rec, startErr: startErr},
+		&fakeService{name: "d", rec: rec},
+	)
+
+	err := g.Start()
+	if !errors.Is(err, startErr) {
+		t.Fatalf("got %v; want %v", err, startErr)
+	}
+
+	want := []string{"start a", "start b", "stop b", "stop a"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestGroupInitRollback(t *testing.T) {
+	rec := &recorder{}
+	initErr := errors.New("bad config")
+	g := NewGroup(
+		&fakeService{name: "a", rec: rec},
+		&fakeService{name: "b", rec: rec},
+		&fakeService{name: "c", rec: rec, initErr: initErr},
+		&fakeService{name: "d", rec: rec},
+	)
+
+	if err := g.Init(environment{}); !errors.Is(err, initErr) {
+		t.Fatalf("got %v; want %v", err, initErr)
+	}
+	want := []string{"init a", "init b", "stop b", "stop a"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+type reloadService struct {
+	fakeService
+	err error
+}
+
+func (r *reloadService) Reload() error {
+	r.rec.add("reload " + r.name)
+	return r.err
+}
+
+func TestGroupReload(t *testing.T) {
+	rec := &recorder{}
+	g := NewGroup(&fakeService{name: "a", rec: rec})
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Reload(); !errors.Is(err, ErrReloadUnsupported) {
+		t.Errorf("without reloader: got %v; want %v", err, ErrReloadUnsupported)
+	}
+
+	// 嵌套的组没有成员支持重新加载时跳过
+	reloadErr := errors.New("bad config")
+	g = NewGroup(
+		NewGroup(&fakeService{name: "b", rec: rec}),
+		&reloadService{fakeService: fakeService{name: "c", rec: rec}},
+		&reloadService{fakeService: fakeService{name: "d", rec: rec}, err: reloadErr},
+	)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Reload(); !errors.Is(err, reloadErr) || errors.Is(err, ErrReloadUnsupported) {
+		t.Errorf("got %v; want %v", err, reloadErr)
+	}
+	want := []string{"start a", "start b", "start c", "start d", "reload c", "reload d"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestRunGroupFatal(t *testing.T) {
+	notify := signalNotify
+	signalNotify = func(chan<- os.Signal, ...os.Signal) {}
+	defer func() { signalNotify = notify }()
+
+	rec := &recorder{}
+	fatalErr := errors.New("worker died")
+	worker := &fakeService{name: "worker", rec: rec, fatal: make(chan error, 1)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunGroup(&fakeService{name: "api", rec: rec}, worker)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	worker.fatal <- fatalErr
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, fatalErr) {
+			t.Errorf("got %v; want %v", err, fatalErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("group was not stopped after a fatal error")
+	}
+
+	want := []string{"init api", "init worker", "start api", "start worker", "stop worker", "stop api"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+type hungService struct {
+	fakeService
+	release chan struct{}
+}
+
+func (h *hungService) Stop() error {
+	<-h.release
+	return nil
+}
+
+func TestRunWithOptionsStopTimeout(t *testing.T) {
+	notify := signalNotify
+	signalNotify = func(c chan<- os.Signal, _ ...os.Signal) {
+		c <- os.Interrupt
+	}
+	defer func() { signalNotify = notify }()
+
+	rec := &recorder{}
+	hung := &hungService{fakeService: fakeService{name: "hung", rec: rec}, release: make(chan struct{})}
+	defer close(hung.release)
+
+	err := RunWithOptions(
+		NewGroup(&fakeService{name: "api", rec: rec}, hung),
+		WithStopTimeout(50*time.Millisecond),
+	)
+
+	var timeout *StopTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("got %v; want *StopTimeoutError", err)
+	}
+	if timeout.Service != "hung" {
+		t.Errorf("got service %q; want %q", timeout.Service, "hung")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v should be a context.DeadlineExceeded", err)
+	}
+}
+
+type slowService struct {
+	fakeService
+	delay time.Duration
+}
+
+func (s *slowService) Stop() error {
+	time.Sleep(s.delay)
+	return s.fakeService.Stop()
+}
+
+func TestGroupStopOrderAfterDeadline(t *testing.T) {
+	rec := &recorder{}
+	hung := &hungService{fakeService: fakeService{name: "hung", rec: rec}, release: make(chan struct{})}
+	defer close(hung.release)
+	g := NewGroup(
+		&fakeService{name: "a", rec: rec},
+		&slowService{fakeService: fakeService{name: "b", rec: rec}, delay: 20 * time.Millisecond},
+		hung,
+	)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := g.StopContext(ctx)
+	var timeout *StopTimeoutError
+	if !errors.As(err, &timeout) || timeout.Service != "hung" {
+		t.Fatalf("got %v; want *StopTimeoutError for hung", err)
+	}
+	// 超时之后剩余的成员仍然按相反的顺序依次停止
+	want := []string{"start a", "start b", "start hung", "stop b", "stop a"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
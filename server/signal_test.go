@@ -0,0 +1,74 @@
+//go:build !windows
+
+package server
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/lshaofan/cb-framework/core/logger"
+	"github.com/sirupsen/logrus"
+)
+
+func quietLogger() *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return l
+}
+
+func TestControlReload(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	o := newOptions(WithLogger(quietLogger()))
+	rec := &recorder{}
+	svc := &reloadService{fakeService: fakeService{name: "api", rec: rec}}
+
+	if o.control(svc, environment{}, reloadSignal) {
+		t.Error("reload should not stop the service")
+	}
+	svc.err = errors.New("bad config")
+	if o.control(svc, environment{}, reloadSignal) {
+		t.Error("failed reload should not stop the service")
+	}
+	if got, want := rec.list(), []string{"reload api", "reload api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if got := o.controlSignals(svc); !reflect.DeepEqual(got, []os.Signal{reloadSignal, reopenSignal}) {
+		t.Errorf("got %v", got)
+	}
+
+	svc.err = ErrReloadUnsupported
+	if o.control(svc, environment{}, reloadSignal) {
+		t.Error("unsupported reload should not stop the service")
+	}
+}
+
+func TestControlReopen(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	w, err := logger.GetOutput("test", "reopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("before\n"))
+	name := w.CurrentFileName()
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newOptions(WithLogger(quietLogger()))
+	if o.control(&fakeService{name: "api", rec: &recorder{}}, environment{}, reopenSignal) {
+		t.Error("reopen should not stop the service")
+	}
+	_, _ = w.Write([]byte("after\n"))
+	if data, err := os.ReadFile(w.CurrentFileName()); err != nil || string(data) != "after\n" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// reloadSignal triggers Reloader.Reload.
+	reloadSignal os.Signal = syscall.SIGHUP
+	// reopenSignal reopens the log files created by logger.GetOutput.
+	reopenSignal os.Signal = syscall.SIGUSR1
+	// upgradeSignal starts a graceful upgrade when enabled with WithGracefulUpgrade.
+	upgradeSignal os.Signal = syscall.SIGUSR2
+)
@@ -0,0 +1,17 @@
+//go:build windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// reloadSignal triggers Reloader.Reload.
+	reloadSignal os.Signal = syscall.SIGHUP
+	// reopenSignal is not available on Windows.
+	reopenSignal os.Signal
+	// upgradeSignal is not available on Windows.
+	upgradeSignal os.Signal
+)
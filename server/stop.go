@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// stopGracePeriod is how long a service may take to return after the stop
+// context is done, before it is reported as timed out.
+const stopGracePeriod = 100 * time.Millisecond
+
+// memberGracePeriod is the grace period of each member of a Group. Members
+// still running when the deadline has passed are stopped one after the other
+// in reverse order, each within this period, which is shorter than
+// stopGracePeriod so that a single hung member is reported by its own name.
+const memberGracePeriod = stopGracePeriod / 2
+
+// StopTimeoutError is returned when a service did not stop before the
+// deadline configured with WithStopTimeout.
+type StopTimeoutError struct {
+	// Service is the name of the service which exceeded the deadline.
+	Service string
+}
+
+func (e *StopTimeoutError) Error() string {
+	return fmt.Sprintf("server: %s did not stop before the deadline", e.Service)
+}
+
+// Unwrap allows errors.Is(err, context.DeadlineExceeded).
+func (e *StopTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// stopContext stops s, returning a *StopTimeoutError if it has not stopped
+// within grace after ctx is done. A service which does not return is left
+// running in the background.
+func stopContext(ctx context.Context, s Service, grace time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		if cs, ok := s.(ContextStopper); ok {
+			done <- cs.StopContext(ctx)
+		} else {
+			done <- s.Stop()
+		}
+	}()
+
+	select {
+	case err := <-done:
+		return timeoutError(s, err)
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return timeoutError(s, err)
+	case <-time.After(grace):
+	}
+	return &StopTimeoutError{Service: serviceName(s)}
+}
+
+// timeoutError names s in a bare deadline error returned by its StopContext.
+func timeoutError(s Service, err error) error {
+	var timeout *StopTimeoutError
+	if errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &timeout) {
+		return &StopTimeoutError{Service: serviceName(s)}
+	}
+	return err
+}
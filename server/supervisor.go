@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// RestartPolicy tells a Supervisor when to restart its service.
+type RestartPolicy int
+
+const (
+	// RestartNever never restarts the service.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts the service when it reports a non-nil error.
+	RestartOnFailure
+	// RestartAlways restarts the service whenever it ends, even without an error.
+	RestartAlways
+)
+
+// ErrTooManyRestarts is reported by a Supervisor when its service was
+// restarted more often than allowed within the configured window.
+var ErrTooManyRestarts = errors.New("too many restarts")
+
+// ErrNotFataler is returned by NewSupervisor when the service does not
+// implement Fataler, as the Supervisor could never notice that it ended.
+var ErrNotFataler = errors.New("server: supervised service does not implement Fataler")
+
+// RestartEvent describes a restart about to happen.
+type RestartEvent struct {
+	// Service is the name of the restarted service.
+	Service string
+	// Attempt is the number of the restart within the current window, starting at 1.
+	Attempt int
+	// Err is the error the service ended with, nil when it ended cleanly.
+	Err error
+	// Delay is how long the Supervisor waits before restarting.
+	Delay time.Duration
+}
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithRestartPolicy sets the restart policy. The default is RestartOnFailure.
+func WithRestartPolicy(policy RestartPolicy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.policy = policy
+	}
+}
+
+// WithBackoff sets the delay before the first restart and the maximum delay.
+// The delay doubles on every restart within the window.
+// The default is 100ms up to 30s.
+func WithBackoff(initial, max time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.initialDelay = initial
+		s.maxDelay = max
+	}
+}
+
+// WithJitter randomizes each delay by up to the given fraction of it,
+// e.g. 0.2 for ±20%. The default is 0.2.
+func WithJitter(fraction float64) SupervisorOption {
+	return func(s *Supervisor) {
+		s.jitter = fraction
+	}
+}
+
+// WithMaxRestarts gives up restarting once the service was restarted max
+// times within window. A max of zero allows unlimited restarts.
+// The default is 5 restarts per minute.
+func WithMaxRestarts(max int, window time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxRestarts = max
+		s.window = window
+	}
+}
+
+// OnRestart sets a callback invoked before every restart.
+func OnRestart(fn func(RestartEvent)) SupervisorOption {
+	return func(s *Supervisor) {
+		s.onRestart = fn
+	}
+}
+
+// OnGiveUp sets a callback invoked when the Supervisor stops restarting its
+// service because of the policy or the restart limit.
+func OnGiveUp(fn func(error)) SupervisorOption {
+	return func(s *Supervisor) {
+		s.onGiveUp = fn
+	}
+}
+
+// Supervisor is a Service wrapping another Service and restarting it
+// according to a RestartPolicy.
+//
+// The wrapped service reports that it ended through Fataler: a non-nil
+// error means it failed, a nil error or a closed channel means it ended
+// cleanly. Fatal() is called again after every restart. Before restarting,
+// the service is stopped so that it can release its resources.
+//
+// When the Supervisor gives up, it reports the last error through its own
+// Fatal() channel so that Run or a Group stops.
+//
+// Handler, Reloader and Context are forwarded to the supervised service.
+// Without them, Handle returns ErrStop, Reload returns ErrReloadUnsupported
+// and Context returns a context which is never done, as for a service which
+// does not implement them.
+type Supervisor struct {
+	service Service
+
+	policy       RestartPolicy
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	jitter       float64
+	maxRestarts  int
+	window       time.Duration
+	onRestart    func(RestartEvent)
+	onGiveUp     func(error)
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	fatal    chan error
+	wg       sync.WaitGroup
+	restarts []time.Time
+}
+
+// NewSupervisor creates a Supervisor for service. It returns ErrNotFataler
+// when service does not implement Fataler.
+func NewSupervisor(service Service, opts ...SupervisorOption) (*Supervisor, error) {
+	if _, ok := service.(Fataler); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFataler, serviceName(service))
+	}
+	s := &Supervisor{
+		service:      service,
+		policy:       RestartOnFailure,
+		initialDelay: 100 * time.Millisecond,
+		maxDelay:     30 * time.Second,
+		jitter:       0.2,
+		maxRestarts:  5,
+		window:       time.Minute,
+		fatal:        make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// Name returns the name of the supervised service.
+func (s *Supervisor) Name() string {
+	return serviceName(s.service)
+}
+
+// Init calls Init on the supervised service.
+func (s *Supervisor) Init(env Environment) error {
+	return s.service.Init(env)
+}
+
+// Start starts the supervised service and begins watching it.
+func (s *Supervisor) Start() error {
+	if err := s.service.Start(); err != nil {
+		return err
+	}
+	s.wg.Add(1)
+	go s.watch()
+	return nil
+}
+
+// Stop stops watching and stops the supervised service.
+func (s *Supervisor) Stop() error {
+	return s.StopContext(context.Background())
+}
+
+// StopContext stops watching and stops the supervised service with ctx.
+func (s *Supervisor) StopContext(ctx context.Context) error {
+	s.cancel()
+	s.wg.Wait()
+	if cs, ok := s.service.(ContextStopper); ok {
+		return cs.StopContext(ctx)
+	}
+	return s.service.Stop()
+}
+
+// Fatal implements Fataler.
+func (s *Supervisor) Fatal() <-chan error {
+	return s.fatal
+}
+
+// Handle forwards the signal to the supervised service implementing Handler.
+func (s *Supervisor) Handle(sig os.Signal) error {
+	if h, ok := s.service.(Handler); ok {
+		return h.Handle(sig)
+	}
+	return ErrStop
+}
+
+// Reload forwards the reload to the supervised service implementing Reloader.
+func (s *Supervisor) Reload() error {
+	if r, ok := s.service.(Reloader); ok {
+		return r.Reload()
+	}
+	return ErrReloadUnsupported
+}
+
+// Context returns the context of the supervised service implementing Context.
+func (s *Supervisor) Context() context.Context {
+	if c, ok := s.service.(Context); ok {
+		return c.Context()
+	}
+	return context.Background()
+}
+
+func (s *Supervisor) watch() {
+	defer s.wg.Done()
+	for {
+		err, ok := s.wait()
+		if !ok {
+			return
+		}
+
+	restart:
+		if !s.shouldRestart(err) {
+			s.giveUp(err)
+			return
+		}
+		attempt := s.recordRestart()
+		if s.maxRestarts > 0 && attempt > s.maxRestarts {
+			s.giveUp(fmt.Errorf("%w: %d within %s: %w", ErrTooManyRestarts, s.maxRestarts, s.window, err))
+			return
+		}
+
+		delay := s.delay(attempt)
+		if s.onRestart != nil {
+			s.onRestart(RestartEvent{Service: s.Name(), Attempt: attempt, Err: err, Delay: delay})
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		_ = s.service.Stop()
+		if err = s.service.Start(); err != nil {
+			goto restart
+		}
+	}
+}
+
+// wait blocks until the service ends, returning false when the Supervisor is stopped.
+func (s *Supervisor) wait() (error, bool) {
+	ch := s.service.(Fataler).Fatal()
+	select {
+	case err := <-ch:
+		return err, true
+	case <-s.ctx.Done():
+		return nil, false
+	}
+}
+
+func (s *Supervisor) shouldRestart(err error) bool {
+	switch s.policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// recordRestart records a restart and returns the number of restarts within the window.
+func (s *Supervisor) recordRestart() int {
+	now := time.Now()
+	kept := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.window {
+			kept = append(kept, t)
+		}
+	}
+	s.restarts = append(kept, now)
+	return len(s.restarts)
+}
+
+// delay returns the backoff before the given attempt, with jitter.
+func (s *Supervisor) delay(attempt int) time.Duration {
+	d := s.initialDelay
+	for i := 1; i < attempt && d < s.maxDelay; i++ {
+		d *= 2
+	}
+	if d > s.maxDelay {
+		d = s.maxDelay
+	}
+	if s.jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * s.jitter * float64(d))
+	}
+	return d
+}
+
+func (s *Supervisor) giveUp(err error) {
+	if s.onGiveUp != nil {
+		s.onGiveUp(err)
+	}
+	if err != nil {
+		s.fatal <- fmt.Errorf("%s: %w", s.Name(), err)
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type crashingService struct {
+	mu     sync.Mutex
+	starts int
+	fatal  chan error
+}
+
+func (c *crashingService) Init(Environment) error { return nil }
+
+func (c *crashingService) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.starts++
+	c.fatal = make(chan error, 1)
+	return nil
+}
+
+func (c *crashingService) Stop() error { return nil }
+
+func (c *crashingService) Fatal() <-chan error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fatal
+}
+
+func (c *crashingService) crash(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fatal <- err
+}
+
+func TestSupervisorRestartsUntilLimit(t *testing.T) {
+	svc := &crashingService{}
+	crashErr := errors.New("worker died")
+	var events []RestartEvent
+	s, err := NewSupervisor(svc,
+		WithBackoff(time.Millisecond, 4*time.Millisecond),
+		WithJitter(0),
+		WithMaxRestarts(2, time.Minute),
+		OnRestart(func(e RestartEvent) { events = append(events, e) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		svc.crash(crashErr)
+		if i < 2 {
+			// wait for the restart
+			for start := time.Now(); ; {
+				svc.mu.Lock()
+				starts := svc.starts
+				svc.mu.Unlock()
+				if starts == i+2 {
+					break
+				}
+				if time.Since(start) > time.Second {
+					t.Fatalf("service was not restarted, starts: %d", starts)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	select {
+	case err := <-s.Fatal():
+		if !errors.Is(err, ErrTooManyRestarts) || !errors.Is(err, crashErr) {
+			t.Errorf("got %v; want %v wrapping %v", err, ErrTooManyRestarts, crashErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+
+	if len(events) != 2 || events[0].Delay != time.Millisecond || events[1].Delay != 2*time.Millisecond {
+		t.Errorf("unexpected restart events: %+v", events)
+	}
+}
+
+func TestSupervisorCleanExit(t *testing.T) {
+	svc := &crashingService{}
+	s, err := NewSupervisor(svc, WithRestartPolicy(RestartOnFailure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	svc.crash(nil)
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-s.Fatal():
+		t.Errorf("got %v; want no fatal error", err)
+	default:
+	}
+	if svc.starts != 1 {
+		t.Errorf("got %d starts; want 1", svc.starts)
+	}
+}
+
+// plainService does not implement Fataler.
+type plainService struct{}
+
+func (plainService) Init(Environment) error { return nil }
+func (plainService) Start() error           { return nil }
+func (plainService) Stop() error            { return nil }
+
+func TestSupervisorRequiresFataler(t *testing.T) {
+	_, err := NewSupervisor(plainService{})
+	if !errors.Is(err, ErrNotFataler) {
+		t.Errorf("got %v; want %v", err, ErrNotFataler)
+	}
+}
+
+// forwardingService implements the optional interfaces forwarded by a Supervisor.
+type forwardingService struct {
+	crashingService
+	ctx     context.Context
+	reloads int
+	signals []os.Signal
+}
+
+func (f *forwardingService) Reload() error {
+	f.reloads++
+	return nil
+}
+
+func (f *forwardingService) Handle(sig os.Signal) error {
+	f.signals = append(f.signals, sig)
+	return nil
+}
+
+func (f *forwardingService) Context() context.Context {
+	return f.ctx
+}
+
+func TestSupervisorForwards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := &forwardingService{ctx: ctx}
+	s, err := NewSupervisor(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err != nil || svc.reloads != 1 {
+		t.Errorf("reload = %v, %d reloads", err, svc.reloads)
+	}
+	if err := s.Handle(os.Interrupt); err != nil || len(svc.signals) != 1 {
+		t.Errorf("handle = %v, signals %v", err, svc.signals)
+	}
+	if s.Context() != ctx {
+		t.Error("context was not forwarded")
+	}
+
+	plain, err := NewSupervisor(&crashingService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Reload(); !errors.Is(err, ErrReloadUnsupported) {
+		t.Errorf("reload = %v; want %v", err, ErrReloadUnsupported)
+	}
+	if err := plain.Handle(os.Interrupt); !errors.Is(err, ErrStop) {
+		t.Errorf("handle = %v; want %v", err, ErrStop)
+	}
+	if plain.Context().Done() != nil {
+		t.Error("context of a plain service should never be done")
+	}
+}
@@ -4,6 +4,7 @@ package server
 import (
 	"context"
 	"errors"
+	"net"
 	"os"
 	"os/signal"
 )
@@ -13,6 +14,11 @@ var signalNotify = signal.Notify
 
 var ErrStop = errors.New("stopping service")
 
+// ErrReloadUnsupported is returned by Reload from a Service wrapping other
+// services when none of them implements Reloader. Run logs it instead of
+// reporting a successful reload.
+var ErrReloadUnsupported = errors.New("server: reload not supported")
+
 // Service interface contains Start and Stop methods which are called
 // when the service is started and stopped. The Init method is called
 // before the service is started, and after it's determined if the program
@@ -49,9 +55,57 @@ type Environment interface {
 	IsWindowsService() bool
 }
 
+// SystemdEnvironment is an optional interface an Environment can implement.
+// The Environment passed to Init by Run implements it, so services can check
+// for it with a type assertion to use listeners inherited from systemd.
+type SystemdEnvironment interface {
+	Environment
+
+	// IsSystemd reports whether the program was started by systemd.
+	IsSystemd() bool
+
+	// Listeners returns the listeners inherited through systemd socket
+	// activation (LISTEN_FDS), in the order they were passed.
+	Listeners() []net.Listener
+
+	// Listener returns the inherited listener named name in LISTEN_FDNAMES.
+	Listener(name string) (net.Listener, bool)
+}
+
 // Handler is an optional interface a Service can implement.
 // When implemented, Handle() is called when a signal is received.
 // Returning ErrStop from this method will result in Service.Stop() being called.
 type Handler interface {
 	Handle(os.Signal) error
 }
+
+// Namer is an optional interface a Service can implement.
+// When implemented, Name() is used to identify the service in errors
+// returned by a Group. Otherwise the Go type name of the service is used.
+type Namer interface {
+	Name() string
+}
+
+// Fataler is an optional interface a Service can implement.
+// When implemented, the channel returned by Fatal() is watched after the
+// service is started. An error received from it means the service can no
+// longer run and the whole program, or the Group it belongs to, is stopped.
+type Fataler interface {
+	Fatal() <-chan error
+}
+
+// ContextStopper is an optional interface a Service can implement.
+// When implemented, StopContext() is called instead of Stop(). The context
+// carries the deadline configured with WithStopTimeout, and the service
+// should return once it is done.
+type ContextStopper interface {
+	StopContext(ctx context.Context) error
+}
+
+// Reloader is an optional interface a Service can implement.
+// When implemented, Reload() is called when syscall.SIGHUP is received,
+// without stopping the service. An error returned from Reload() is logged
+// and the service keeps running.
+type Reloader interface {
+	Reload() error
+}
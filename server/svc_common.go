@@ -2,16 +2,56 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"syscall"
+	"time"
+
+	"github.com/lshaofan/cb-framework/core/logger"
+	"github.com/sirupsen/logrus"
 )
 
+// Create variable os.Exit function so we can mock it in tests
+var osExit = os.Exit
+
 // Run runs your Service.
 //
 // Run will block until one of the signals specified in sig is received or a provided context is done.
 // If sig is empty syscall.SIGINT and syscall.SIGTERM are used by default.
 func Run(service Service, sig ...os.Signal) error {
-	env := environment{}
+	return RunWithOptions(service, WithSignals(sig...))
+}
+
+// RunWithOptions runs your Service like Run, configured by opts.
+//
+// When a stop timeout is configured, the Service is given that long to stop.
+// A Service implementing ContextStopper receives a context with the
+// deadline, and a *StopTimeoutError is returned if the deadline is exceeded.
+// A second signal received while stopping exits the process immediately.
+//
+// Unless they are among the stop signals, syscall.SIGHUP calls Reload on a
+// Service implementing Reloader and syscall.SIGUSR1 reopens the log files
+// created by logger.GetOutput. Errors from both are logged and the Service
+// keeps running.
+//
+// When started by systemd, READY=1 is sent once the Service has started,
+// STOPPING=1 before it is stopped and WATCHDOG=1 periodically when
+// WatchdogSec is configured. Listeners passed by socket activation are
+// available from the Environment given to Init.
+//
+// With WithGracefulUpgrade, syscall.SIGUSR2 starts the current executable
+// again, passing it the inherited listeners and those created by Listen.
+// Once the new process has started, this one is stopped so that it drains
+// its connections while the new one already accepts.
+func RunWithOptions(service Service, opts ...Option) error {
+	o := newOptions(opts...)
+
+	env, err := newEnvironment()
+	if err != nil {
+		return err
+	}
 	if err := service.Init(env); err != nil {
 		return err
 	}
@@ -20,12 +60,25 @@ func Run(service Service, sig ...os.Signal) error {
 		return err
 	}
 
-	if len(sig) == 0 {
-		sig = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	if err := notifyUpgradeReady(); err != nil {
+		o.logger.WithError(err).Error("server: notify the previous process failed")
+	}
+	o.notify(sdReady)
+	if interval := sdWatchdogInterval(); interval > 0 {
+		stopWatchdog := make(chan struct{})
+		defer close(stopWatchdog)
+		go runWatchdog(interval, stopWatchdog, func(err error) {
+			o.logger.WithError(err).Warn("server: systemd watchdog notify failed")
+		})
 	}
 
 	signalChan := make(chan os.Signal, 1)
-	signalNotify(signalChan, sig...)
+	signalNotify(signalChan, o.signals...)
+
+	controlChan := make(chan os.Signal, 1)
+	if control := o.controlSignals(service); len(control) > 0 {
+		signalNotify(controlChan, control...)
+	}
 
 	var ctx context.Context
 	if s, ok := service.(Context); ok {
@@ -34,6 +87,12 @@ func Run(service Service, sig ...os.Signal) error {
 		ctx = context.Background()
 	}
 
+	var fatalChan <-chan error
+	if f, ok := service.(Fataler); ok {
+		fatalChan = f.Fatal()
+	}
+	var fatalErr error
+
 	for {
 		select {
 		case s := <-signalChan:
@@ -45,18 +104,223 @@ func Run(service Service, sig ...os.Signal) error {
 				// this maintains backwards compatibility for Services that do not implement Handle()
 				goto stop
 			}
+		case s := <-controlChan:
+			if o.control(service, env, s) {
+				goto stop
+			}
+		case err, ok := <-fatalChan:
+			if !ok {
+				fatalChan = nil
+				continue
+			}
+			if err != nil {
+				fatalErr = err
+				goto stop
+			}
 		case <-ctx.Done():
 			goto stop
 		}
 	}
 
 stop:
-	return service.Stop()
+	o.notify(sdStopping)
+	if fatalErr == nil {
+		// the context of a Group is cancelled right after its fatal error is sent
+		select {
+		case fatalErr = <-fatalChan:
+		default:
+		}
+	}
+	if err := stopWithSignals(service, o.stopTimeout, signalChan); err != nil {
+		return errors.Join(fatalErr, err)
+	}
+	return fatalErr
+
+}
+
+// stopWithSignals stops service within timeout and exits the process when
+// another signal is received before it has stopped.
+func stopWithSignals(service Service, timeout time.Duration, signalChan <-chan os.Signal) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stopContext(ctx, service, stopGracePeriod)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-signalChan:
+		osExit(1)
+		return nil
+	}
+}
+
+// controlSignals returns the signals handled without stopping service.
+func (o *options) controlSignals(service Service) []os.Signal {
+	var sig []os.Signal
+	if _, ok := service.(Reloader); ok && !o.isStopSignal(reloadSignal) {
+		sig = append(sig, reloadSignal)
+	}
+	if reopenSignal != nil && !o.isStopSignal(reopenSignal) {
+		sig = append(sig, reopenSignal)
+	}
+	if o.upgradeTimeout > 0 && upgradeSignal != nil && !o.isStopSignal(upgradeSignal) {
+		sig = append(sig, upgradeSignal)
+	}
+	return sig
+}
+
+func (o *options) isStopSignal(sig os.Signal) bool {
+	for _, s := range o.signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// control runs the action bound to a control signal and reports whether
+// the service must be stopped.
+func (o *options) control(service Service, env environment, sig os.Signal) bool {
+	switch sig {
+	case reloadSignal:
+		o.notify(sdReloading)
+		defer o.notify(sdReady)
+		if err := service.(Reloader).Reload(); errors.Is(err, ErrReloadUnsupported) {
+			o.logger.Warn("server: reload not supported")
+			return false
+		} else if err != nil {
+			o.logger.WithError(err).Error("server: reload failed")
+			return false
+		}
+		o.logger.Info("server: reloaded")
+	case reopenSignal:
+		if err := logger.Reopen(); err != nil {
+			o.logger.WithError(err).Error("server: reopen log files failed")
+		}
+	case upgradeSignal:
+		pid, err := upgrade(env, o.upgradeTimeout)
+		if err != nil {
+			o.logger.WithError(err).Error("server: upgrade failed")
+			return false
+		}
+		o.logger.WithField("pid", pid).Info("server: upgraded, stopping")
+		o.notify(fmt.Sprintf("MAINPID=%d", pid))
+		return true
+	}
+	return false
+}
+
+// notify sends state to systemd, logging a failure.
+func (o *options) notify(state string) {
+	if err := sdNotify(state); err != nil {
+		o.logger.WithError(err).Warn("server: systemd notify failed")
+	}
+}
 
+type environment struct {
+	systemd   bool
+	listeners []net.Listener
+	names     []string
 }
 
-type environment struct{}
+func newEnvironment() (environment, error) {
+	listeners, names, upgraded, err := inheritedSdListeners()
+	if err != nil {
+		return environment{}, err
+	}
+	if !upgraded {
+		if listeners, names, err = sdListeners(); err != nil {
+			return environment{}, err
+		}
+	}
+	return environment{
+		systemd:   isSystemd() || len(listeners) > 0,
+		listeners: listeners,
+		names:     names,
+	}, nil
+}
 
 func (environment) IsWindowsService() bool {
 	return false
 }
+
+func (e environment) IsSystemd() bool {
+	return e.systemd
+}
+
+func (e environment) Listeners() []net.Listener {
+	return e.listeners
+}
+
+func (e environment) Listener(name string) (net.Listener, bool) {
+	for i, n := range e.names {
+		if n == name {
+			return e.listeners[i], true
+		}
+	}
+	return nil, false
+}
+
+// Option configures RunWithOptions.
+type Option func(*options)
+
+type options struct {
+	signals        []os.Signal
+	stopTimeout    time.Duration
+	upgradeTimeout time.Duration
+	logger         logrus.FieldLogger
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		logger: logrus.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.signals) == 0 {
+		o.signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return o
+}
+
+// WithSignals sets the signals which stop the Service.
+// If sig is empty syscall.SIGINT and syscall.SIGTERM are used.
+func WithSignals(sig ...os.Signal) Option {
+	return func(o *options) {
+		o.signals = sig
+	}
+}
+
+// WithStopTimeout sets how long the Service is given to stop.
+// A timeout of zero, the default, waits forever.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.stopTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger used to report errors which do not stop the
+// Service, such as a failed reload. The logrus standard logger is used by default.
+func WithLogger(l logrus.FieldLogger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithGracefulUpgrade enables graceful upgrades on syscall.SIGUSR2.
+// The new process is killed and this one keeps running if it has not
+// started within timeout.
+func WithGracefulUpgrade(timeout time.Duration) Option {
+	return func(o *options) {
+		o.upgradeTimeout = timeout
+	}
+}
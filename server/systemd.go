@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// listenFdsStart is the first file descriptor passed by systemd socket activation.
+	listenFdsStart = 3
+
+	sdReady     = "READY=1"
+	sdStopping  = "STOPPING=1"
+	sdReloading = "RELOADING=1"
+	sdWatchdog  = "WATCHDOG=1"
+)
+
+// isSystemd reports whether the process was started by systemd.
+func isSystemd() bool {
+	return os.Getenv("INVOCATION_ID") != "" || os.Getenv("NOTIFY_SOCKET") != "" || listenPidMatches()
+}
+
+// sdNotify sends state to the socket in NOTIFY_SOCKET. It does nothing when
+// the variable is not set, i.e. when the unit is not of Type=notify.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	if name[0] == '@' {
+		// abstract socket
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns how often WATCHDOG=1 must be sent, which is
+// half of WATCHDOG_USEC, or zero when the watchdog is not enabled.
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// runWatchdog sends WATCHDOG=1 every interval until stop is closed.
+func runWatchdog(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := sdNotify(sdWatchdog); err != nil {
+				onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func listenPidMatches() bool {
+	return os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid())
+}
+
+// sdListeners returns the listeners passed by systemd socket activation,
+// with their names from LISTEN_FDNAMES. The LISTEN_* variables are unset so
+// that child processes do not inherit them.
+func sdListeners() ([]net.Listener, []string, error) {
+	if !listenPidMatches() {
+		return nil, nil, nil
+	}
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, n)
+	listenerNames := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		l, err := fileListener(uintptr(listenFdsStart+i), name)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, nil, err
+		}
+		listeners = append(listeners, l)
+		listenerNames = append(listenerNames, name)
+	}
+	return listeners, listenerNames, nil
+}
+
+// fileListener creates a listener from an inherited file descriptor.
+// The descriptor is closed, the listener holds a duplicate of it.
+func fileListener(fd uintptr, name string) (net.Listener, error) {
+	f := os.NewFile(fd, name)
+	defer f.Close()
+	return net.FileListener(f)
+}
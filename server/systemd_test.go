@@ -0,0 +1,118 @@
+//go:build !windows
+
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	if err := sdNotify(sdReady); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != sdReady {
+		t.Errorf("got %q; want %q", got, sdReady)
+	}
+}
+
+func TestSdNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify(sdReady); err != nil {
+		t.Errorf("got %v; want nil", err)
+	}
+}
+
+func TestRunWithOptionsNotifiesSystemd(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	notify := signalNotify
+	stop := make(chan os.Signal, 1)
+	signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+		if reflect.DeepEqual(sig, []os.Signal{os.Interrupt}) {
+			go func() {
+				<-stop
+				c <- os.Interrupt
+			}()
+		}
+	}
+	defer func() { signalNotify = notify }()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunWithOptions(&fakeService{name: "api", rec: &recorder{}}, WithSignals(os.Interrupt))
+	}()
+
+	if got := readNotify(t, conn); got != sdReady {
+		t.Fatalf("got %q; want %q", got, sdReady)
+	}
+	if got := readNotify(t, conn); got != sdWatchdog {
+		t.Fatalf("got %q; want %q", got, sdWatchdog)
+	}
+
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for {
+		got := readNotify(t, conn)
+		if got == sdStopping {
+			break
+		}
+		if got != sdWatchdog {
+			t.Fatalf("got %q; want %q", got, sdStopping)
+		}
+	}
+}
+
+func TestSystemdEnvironment(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var env Environment = environment{systemd: true, listeners: []net.Listener{ln}, names: []string{"http"}}
+	sd, ok := env.(SystemdEnvironment)
+	if !ok {
+		t.Fatal("environment does not implement SystemdEnvironment")
+	}
+	if got, ok := sd.Listener("http"); !ok || got != ln {
+		t.Errorf("got %v, %v; want %v", got, ok, ln)
+	}
+	if _, ok := sd.Listener("grpc"); ok {
+		t.Error("unknown listener found")
+	}
+	if !sd.IsSystemd() || len(sd.Listeners()) != 1 {
+		t.Errorf("systemd = %v, listeners = %v", sd.IsSystemd(), sd.Listeners())
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envUpgradeFds lists the keys of the listeners passed to an upgraded
+	// process, starting at file descriptor 3.
+	envUpgradeFds = "CB_UPGRADE_FDS"
+	// envUpgradeReadyFd is the file descriptor the upgraded process writes
+	// to once it has started.
+	envUpgradeReadyFd = "CB_UPGRADE_READY_FD"
+
+	// sdKeyPrefix marks the listeners which came from systemd socket activation.
+	sdKeyPrefix = "sd:"
+
+	upgradeReady = "READY"
+)
+
+var (
+	// ErrUpgradeInProgress is returned when an upgrade is requested while
+	// another one is running.
+	ErrUpgradeInProgress = errors.New("server: upgrade already in progress")
+
+	inheritOnce sync.Once
+	inheritErr  error
+	// inheritedKeys holds the keys of the listeners passed by the parent process.
+	inheritedKeys []string
+	// inherited holds the listeners passed by the parent process, by key.
+	inherited = make(map[string]net.Listener)
+
+	listenersMu sync.Mutex
+	// listeners holds the listeners created by Listen, handed off on upgrade.
+	listeners []handoff
+
+	upgrading sync.Mutex
+)
+
+type handoff struct {
+	key string
+	l   net.Listener
+}
+
+// filer is implemented by *net.TCPListener and *net.UnixListener.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Listen announces on the local network address like net.Listen.
+//
+// When the process was started by a graceful upgrade and the parent passed
+// a listener for the same network and address, that listener is returned
+// instead, so that no connection is refused during the upgrade. The
+// returned listener is passed on to the next process on upgrade.
+func Listen(network, address string) (net.Listener, error) {
+	if err := inheritListeners(); err != nil {
+		return nil, err
+	}
+	key := network + ":" + address
+
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	l, ok := inherited[key]
+	if ok {
+		delete(inherited, key)
+	} else {
+		var err error
+		if l, err = net.Listen(network, address); err != nil {
+			return nil, err
+		}
+	}
+	listeners = append(listeners, handoff{key: key, l: l})
+	return l, nil
+}
+
+// inheritListeners reads the listeners passed by the parent process once.
+func inheritListeners() error {
+	inheritOnce.Do(func() {
+		keys := os.Getenv(envUpgradeFds)
+		if keys == "" {
+			return
+		}
+		_ = os.Unsetenv(envUpgradeFds)
+		inheritedKeys = strings.Split(keys, ",")
+		for i, key := range inheritedKeys {
+			l, err := fileListener(uintptr(listenFdsStart+i), key)
+			if err != nil {
+				inheritErr = fmt.Errorf("server: inherit listener %s: %w", key, err)
+				return
+			}
+			inherited[key] = l
+		}
+	})
+	return inheritErr
+}
+
+// inheritedSdListeners returns the systemd listeners passed by the parent
+// process, ok is false when the process was not started by an upgrade.
+func inheritedSdListeners() (ls []net.Listener, names []string, ok bool, err error) {
+	if err := inheritListeners(); err != nil {
+		return nil, nil, true, err
+	}
+	if len(inheritedKeys) == 0 {
+		return nil, nil, false, nil
+	}
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	for _, key := range inheritedKeys {
+		if l, found := inherited[key]; found && strings.HasPrefix(key, sdKeyPrefix) {
+			delete(inherited, key)
+			ls = append(ls, l)
+			names = append(names, strings.TrimPrefix(key, sdKeyPrefix))
+		}
+	}
+	return ls, names, true, nil
+}
+
+// notifyUpgradeReady tells the parent process that this process has started.
+func notifyUpgradeReady() error {
+	fd := os.Getenv(envUpgradeReadyFd)
+	if fd == "" {
+		return nil
+	}
+	_ = os.Unsetenv(envUpgradeReadyFd)
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(n), "upgrade-ready")
+	defer f.Close()
+	_, err = f.Write([]byte(upgradeReady))
+	return err
+}
+
+// upgrade starts a new process from the current executable, passing it the
+// listeners of env and those created by Listen, and waits until it has
+// started or timeout elapses. It returns the pid of the new process.
+func upgrade(env environment, timeout time.Duration) (int, error) {
+	if !upgrading.TryLock() {
+		return 0, ErrUpgradeInProgress
+	}
+	defer upgrading.Unlock()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	handoffs := make([]handoff, 0, len(env.listeners))
+	for i, l := range env.listeners {
+		handoffs = append(handoffs, handoff{key: sdKeyPrefix + env.names[i], l: l})
+	}
+	listenersMu.Lock()
+	handoffs = append(handoffs, listeners...)
+	listenersMu.Unlock()
+
+	keys := make([]string, 0, len(handoffs))
+	files := make([]*os.File, 0, len(handoffs)+1)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, h := range handoffs {
+		fl, ok := h.l.(filer)
+		if !ok {
+			return 0, fmt.Errorf("server: listener %s cannot be passed to another process", h.key)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return 0, err
+		}
+		if ul, ok := h.l.(*net.UnixListener); ok {
+			// the new process keeps using the socket file
+			ul.SetUnlinkOnClose(false)
+		}
+		keys = append(keys, h.key)
+		files = append(files, f)
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer ready.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		envUpgradeFds+"="+strings.Join(keys, ","),
+		envUpgradeReadyFd+"="+strconv.Itoa(listenFdsStart+len(files)-1),
+	)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	// only the new process keeps the write end, so that a read returns once it exits
+	_ = readyW.Close()
+	files = files[:len(files)-1]
+
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, len(upgradeReady))
+		_, err := io.ReadFull(ready, buf)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = errors.New("server: new process exited before it was ready")
+		} else if err == nil && string(buf) != upgradeReady {
+			err = fmt.Errorf("server: unexpected upgrade message %q", buf)
+		}
+		result <- err
+	}()
+
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = fmt.Errorf("server: new process did not start within %s", timeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		go func() { _ = cmd.Wait() }()
+		return 0, err
+	}
+	// the new process is not waited for, it outlives this one
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release()
+	return pid, nil
+}
@@ -0,0 +1,112 @@
+//go:build !windows
+
+package server
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// envUpgradeHelper 在子进程中运行 TestUpgradeHelper
+const envUpgradeHelper = "CB_TEST_UPGRADE_HELPER"
+
+// TestUpgradeHelper 模拟平滑升级启动的新进程 使用继承的监听响应连接
+func TestUpgradeHelper(t *testing.T) {
+	addr := os.Getenv(envUpgradeHelper)
+	if addr == "" {
+		t.Skip("helper process")
+	}
+	l, err := Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, names, ok, err := inheritedSdListeners()
+	if err != nil || !ok || len(sd) != 1 || names[0] != "web" {
+		t.Fatalf("sd listeners = %v %v %v %v", sd, names, ok, err)
+	}
+	if err := notifyUpgradeReady(); err != nil {
+		t.Fatal(err)
+	}
+	for _, ln := range []net.Listener{l, sd[0]} {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = conn.Write([]byte(ln.Addr().String()))
+		_ = conn.Close()
+	}
+}
+
+func listenerFile(t *testing.T) (net.Listener, *os.File) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return l, f
+}
+
+func TestUpgradeInheritListeners(t *testing.T) {
+	sd, sdFile := listenerFile(t)
+	l, lFile := listenerFile(t)
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestUpgradeHelper$")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{sdFile, lFile, readyW}
+	cmd.Env = append(os.Environ(),
+		envUpgradeHelper+"="+l.Addr().String(),
+		envUpgradeFds+"="+sdKeyPrefix+"web,tcp:"+l.Addr().String(),
+		envUpgradeReadyFd+"="+strconv.Itoa(listenFdsStart+2),
+	)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = readyW.Close()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	buf := make([]byte, len(upgradeReady))
+	if _, err := io.ReadFull(ready, buf); err != nil || string(buf) != upgradeReady {
+		t.Fatalf("ready = %q %v", buf, err)
+	}
+	// 父进程不再接受连接 连接由子进程继承的监听处理
+	for _, ln := range []net.Listener{l, sd} {
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		got, err := io.ReadAll(conn)
+		_ = conn.Close()
+		if err != nil || string(got) != ln.Addr().String() {
+			t.Errorf("response = %q %v; want %s", got, err, ln.Addr())
+		}
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("helper: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("helper did not exit")
+	}
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/core/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type AccessLogOption func(*accessLogOptions)
+
+type accessLogOptions struct {
+	output        io.Writer
+	slowThreshold time.Duration
+	skipPaths     map[string]struct{}
+	skipPrefixes  []string
+	skipper       func(c *gin.Context) bool
+	userID        func(c *gin.Context) string
+	sampleAbove   int
+	sampleRate    float64
+	query         bool
+	redact        map[string]struct{}
+}
+
+// defaultRedactParams 记录查询参数时默认隐藏值的参数 不区分大小写
+var defaultRedactParams = []string{"token", "access_token", "refresh_token", "password", "secret", "sign", "signature", "code", "key"}
+
+// WithAccessLogOutput 设置访问日志的输出 默认为 runtime/log/<日期>/http/access.log
+func WithAccessLogOutput(w io.Writer) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.output = w
+	}
+}
+
+// WithSlowThreshold 设置慢请求的阈值 超过阈值的请求以warning级别记录并标记 slow 默认为1秒 为0时不检查
+func WithSlowThreshold(d time.Duration) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.slowThreshold = d
+	}
+}
+
+// WithSkipPaths 不记录的请求路径 例如健康检查 以 /* 结尾时按前缀匹配
+func WithSkipPaths(paths ...string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		for _, p := range paths {
+			if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+				o.skipPrefixes = append(o.skipPrefixes, prefix+"/")
+				continue
+			}
+			o.skipPaths[p] = struct{}{}
+		}
+	}
+}
+
+// WithAccessLogSkipper 设置跳过记录的函数 返回true时不记录
+func WithAccessLogSkipper(fn func(c *gin.Context) bool) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.skipper = fn
+	}
+}
+
+// WithUserIDKey 从gin上下文的key中获取用户id 默认使用 SetUserID 保存的用户id
+func WithUserIDKey(key string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.userID = func(c *gin.Context) string {
+			v, ok := c.Get(key)
+			if !ok || v == nil {
+				return ""
+			}
+			return fmt.Sprint(v)
+		}
+	}
+}
+
+// WithUserIDFunc 设置获取用户id的函数
+func WithUserIDFunc(fn func(c *gin.Context) string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.userID = fn
+	}
+}
+
+// WithQuery 记录查询参数 默认不记录 查询参数中可能包含token等敏感信息
+// token password secret sign code 等参数以及 redact 中的参数的值记录为 *** 参数名不区分大小写
+func WithQuery(redact ...string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.query = true
+		for _, k := range redact {
+			o.redact[strings.ToLower(k)] = struct{}{}
+		}
+	}
+}
+
+// WithSampling 每秒请求数超过 perSecond 后 成功的请求只按 rate 的比例记录
+// 每秒请求数包括所有记录的请求 错误和慢请求也计入 但总是记录 被采样的日志带有 sample_rate 字段
+func WithSampling(perSecond int, rate float64) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.sampleAbove = perSecond
+		o.sampleRate = rate
+	}
+}
+
+// AccessLog 结构化的JSON访问日志中间件 记录请求的路由模板、客户端ip、用户id、请求id、请求和响应大小、状态码和耗时
+// 状态码>=500为error级别 >=400或慢请求为warning级别 其余为info级别
+func AccessLog(opts ...AccessLogOption) gin.HandlerFunc {
+	o := &accessLogOptions{
+		slowThreshold: time.Second,
+		skipPaths:     make(map[string]struct{}),
+		userID:        GetUserID,
+		redact:        make(map[string]struct{}),
+	}
+	for _, k := range defaultRedactParams {
+		o.redact[k] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.output == nil {
+		writer, err := logger.GetOutput("http", "access")
+		if err != nil {
+			panic(err)
+		}
+		o.output = writer
+	}
+	l := newJSONLogger(o.output)
+	s := &sampler{above: o.sampleAbove, rate: o.sampleRate}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if o.skip(c, path) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		body := &countingReader{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil {
+			c.Request.Body = body
+		}
+		c.Next()
+		latency := time.Since(start)
+
+		status := c.Writer.Status()
+		slow := o.slowThreshold > 0 && latency >= o.slowThreshold
+		fields := logrus.Fields{}
+		// 所有请求都计入每秒的请求数 只有成功且不慢的请求会被采样丢弃
+		if s.over(start) && status < 400 && !slow {
+			if !s.keep() {
+				return
+			}
+			fields["sample_rate"] = s.rate
+		}
+
+		requestSize := c.Request.ContentLength
+		if body.n > requestSize {
+			requestSize = body.n
+		}
+		responseSize := c.Writer.Size()
+		if responseSize < 0 {
+			responseSize = 0
+		}
+		fields["method"] = c.Request.Method
+		fields["path"] = path
+		fields["route"] = c.FullPath()
+		if o.query && c.Request.URL.RawQuery != "" {
+			fields["query"] = o.redactQuery(c.Request.URL.Query())
+		}
+		fields["status"] = status
+		fields["latency_ms"] = float64(latency.Microseconds()) / 1000
+		fields["ip"] = c.ClientIP()
+		fields["user_agent"] = c.Request.UserAgent()
+		fields["user_id"] = o.userID(c)
+		fields["request_id"] = GetRequestID(c)
+		fields["request_size"] = max(requestSize, 0)
+		fields["response_size"] = responseSize
+		if slow {
+			fields["slow"] = true
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields["errors"] = errs
+		}
+
+		entry := l.WithFields(fields)
+		switch {
+		case status >= 500:
+			entry.Error()
+		case status >= 400 || slow:
+			entry.Warn()
+		default:
+			entry.Info()
+		}
+	}
+}
+
+func (o *accessLogOptions) skip(c *gin.Context, path string) bool {
+	if _, ok := o.skipPaths[path]; ok {
+		return true
+	}
+	for _, prefix := range o.skipPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return o.skipper != nil && o.skipper(c)
+}
+
+// redactQuery 隐藏敏感参数的值
+func (o *accessLogOptions) redactQuery(values url.Values) string {
+	for k, v := range values {
+		if _, ok := o.redact[strings.ToLower(k)]; ok {
+			for i := range v {
+				v[i] = "***"
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// sampler 按秒统计请求数 超过阈值后按比例采样
+type sampler struct {
+	above int
+	rate  float64
+
+	mu     sync.Mutex
+	second int64
+	count  int
+}
+
+// over 统计一次请求 返回当前秒的请求数是否超过阈值 没有开启采样时返回false
+func (s *sampler) over(now time.Time) bool {
+	if s.above <= 0 || s.rate >= 1 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec := now.Unix()
+	if sec != s.second {
+		s.second = sec
+		s.count = 0
+	}
+	s.count++
+	return s.count > s.above
+}
+
+// keep 按采样率决定是否记录
+func (s *sampler) keep() bool {
+	return rand.Float64() < s.rate
+}
+
+// countingReader 统计实际读取的请求体大小 用于没有 Content-Length 的分块请求
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAccessLogEngine(buf *bytes.Buffer, opts ...AccessLogOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestID(), AccessLog(append([]AccessLogOption{WithAccessLogOutput(buf)}, opts...)...))
+	engine.POST("/users/:id", func(c *gin.Context) {
+		SetUserID(c, c.Param("id"))
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/status/:code", func(c *gin.Context) {
+		switch c.Param("code") {
+		case "404":
+			c.Status(http.StatusNotFound)
+		case "500":
+			c.Status(http.StatusInternalServerError)
+		case "slow":
+			time.Sleep(20 * time.Millisecond)
+			c.Status(http.StatusOK)
+		default:
+			c.Status(http.StatusOK)
+		}
+	})
+	engine.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/metrics/cpu", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func accessLogs(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	buf.Reset()
+	return entries
+}
+
+func TestAccessLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newAccessLogEngine(&buf)
+	req := httptest.NewRequest(http.MethodPost, "/users/42?token=abc", strings.NewReader("hello"))
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "test")
+	serve(engine, req)
+
+	entries := accessLogs(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", entries)
+	}
+	want := map[string]any{
+		"level":         "info",
+		"method":        "POST",
+		"path":          "/users/42",
+		"route":         "/users/:id",
+		"status":        float64(200),
+		"user_id":       "42",
+		"request_id":    "req-1",
+		"user_agent":    "test",
+		"request_size":  float64(5),
+		"response_size": float64(2),
+	}
+	for k, v := range want {
+		if entries[0][k] != v {
+			t.Errorf("%s = %v; want %v", k, entries[0][k], v)
+		}
+	}
+	// 默认不记录查询参数
+	if _, ok := entries[0]["query"]; ok {
+		t.Errorf("query = %v", entries[0]["query"])
+	}
+}
+
+func TestAccessLogQuery(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newAccessLogEngine(&buf, WithQuery("Phone"))
+	serve(engine, httptest.NewRequest(http.MethodGet, "/status/200?page=2&Token=abc&phone=138&sign=x", nil))
+
+	entries := accessLogs(t, &buf)
+	if got := entries[0]["query"]; got != "Token=%2A%2A%2A&page=2&phone=%2A%2A%2A&sign=%2A%2A%2A" {
+		t.Errorf("query = %v", got)
+	}
+}
+
+func TestAccessLogLevelAndSkip(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newAccessLogEngine(&buf, WithSlowThreshold(10*time.Millisecond), WithSkipPaths("/health", "/metrics/*"))
+	for _, target := range []string{"/health", "/metrics/cpu", "/status/404", "/status/500", "/status/slow", "/status/200"} {
+		serve(engine, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	entries := accessLogs(t, &buf)
+	if len(entries) != 4 {
+		t.Fatalf("entries = %v", entries)
+	}
+	for i, level := range []string{"warning", "error", "warning", "info"} {
+		if entries[i]["level"] != level {
+			t.Errorf("%s level = %v; want %s", entries[i]["path"], entries[i]["level"], level)
+		}
+	}
+	if entries[2]["slow"] != true {
+		t.Errorf("slow = %v", entries[2]["slow"])
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newAccessLogEngine(&buf, WithSampling(2, 0))
+	// 错误请求总是记录 但计入每秒的请求数
+	for _, target := range []string{"/status/500", "/status/404", "/status/200", "/status/500"} {
+		serve(engine, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	entries := accessLogs(t, &buf)
+	// 跨过秒的边界时计数会重置 只在同一秒内检查
+	if len(entries) == 4 && entries[2]["status"] == float64(200) {
+		t.Skip("crossed a second boundary")
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %v", entries)
+	}
+	for _, e := range entries {
+		if e["status"] == float64(200) {
+			t.Errorf("sampled request was logged: %v", e)
+		}
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{above: 2, rate: 0.5}
+	now := time.Unix(100, 0)
+	for i, want := range []bool{false, false, true, true} {
+		if got := s.over(now); got != want {
+			t.Errorf("request %d over = %v", i, got)
+		}
+	}
+	if s.over(now.Add(time.Second)) {
+		t.Error("count was not reset in a new second")
+	}
+	if (&sampler{above: 1, rate: 1}).over(now) {
+		t.Error("rate 1 disables sampling")
+	}
+}
@@ -23,3 +23,9 @@ type Action interface {
 	UpdateOkWithMessage(message string)
 	DeleteOkWithMessage(message string)
 }
+
+// SceneBinder 支持场景校验的 Action GinActionImpl 实现了此接口 其他 Action 实现需要类型断言后使用
+type SceneBinder interface {
+	// BindParamWithScene 绑定参数并使用场景校验 场景使用 Validate.RegisterScene 注册
+	BindParamWithScene(param any, scene string) error
+}
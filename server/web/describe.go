@@ -0,0 +1,49 @@
+package web
+
+import (
+	"reflect"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// describeKey gin上下文中存在此key时 Handle 生成的处理函数只返回请求和响应的类型 不处理请求
+const describeKey = "web.describe"
+
+// RouteTypes 路由的请求参数和响应数据类型 用于生成接口文档
+type RouteTypes struct {
+	Request  reflect.Type
+	Response reflect.Type
+}
+
+// handleFuncName Handle 生成的处理函数的函数名 所有类型参数的实例名称相同
+var handleFuncName = funcName(Handle[struct{}, struct{}](nil))
+
+// DescribeRoute 获取 Handle 生成的处理函数的请求参数和响应数据类型 其他处理函数返回false
+// 其他处理函数的类型需要使用 openapi.Generator.Route 设置
+func DescribeRoute(route gin.RouteInfo) (RouteTypes, bool) {
+	if route.HandlerFunc == nil || funcName(route.HandlerFunc) != handleFuncName {
+		return RouteTypes{}, false
+	}
+	c := &gin.Context{}
+	types := &RouteTypes{}
+	c.Set(describeKey, types)
+	route.HandlerFunc(c)
+	return *types, true
+}
+
+// describe Handle 在描述模式下记录类型
+func describe[Req, Resp any](c *gin.Context) bool {
+	v, ok := c.Get(describeKey)
+	if !ok {
+		return false
+	}
+	types := v.(*RouteTypes)
+	types.Request = reflect.TypeOf((*Req)(nil)).Elem()
+	types.Response = reflect.TypeOf((*Resp)(nil)).Elem()
+	return true
+}
+
+func funcName(fn any) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
@@ -16,6 +16,20 @@ var (
 	PlatformNotExist = NewErrorModel(10004, "平台不存在", nil, http.StatusPreconditionFailed)
 	// PlatformIdCanNotEmpty 平台id不能为空
 	PlatformIdCanNotEmpty = NewErrorModel(10005, "平台id不能为空", nil, http.StatusPreconditionFailed)
+	// Forbidden 没有访问权限
+	Forbidden = NewErrorModel(10006, "没有访问权限", nil, http.StatusForbidden)
+	// TooManyRequests 请求过于频繁
+	TooManyRequests = NewErrorModel(10007, "请求过于频繁", nil, http.StatusTooManyRequests)
+	// IdempotencyKeyInProgress 相同幂等键的请求正在处理中
+	IdempotencyKeyInProgress = NewErrorModel(10008, "请求正在处理中", nil, http.StatusConflict)
+	// IdempotencyKeyMismatch 幂等键已用于参数不同的请求
+	IdempotencyKeyMismatch = NewErrorModel(10009, "幂等键已用于其他请求", nil, http.StatusUnprocessableEntity)
+	// IdempotencyKeyRequired 缺少幂等键
+	IdempotencyKeyRequired = NewErrorModel(10010, "缺少幂等键", nil, http.StatusBadRequest)
+	// IdempotencyKeyInvalid 幂等键格式错误 例如超过最大长度
+	IdempotencyKeyInvalid = NewErrorModel(10011, "幂等键格式错误", nil, http.StatusBadRequest)
+	// RequestEntityTooLarge 请求体超过限制
+	RequestEntityTooLarge = NewErrorModel(10012, "请求体过大", nil, http.StatusRequestEntityTooLarge)
 )
 
 // ErrorModel 错误模型
@@ -9,29 +9,36 @@ import (
 )
 
 type GinActionImpl struct {
-	c   *gin.Context
-	res *Response
-	req *Request
+	c      *gin.Context
+	res    *Response
+	req    *Request
+	locale string
 }
 
 /** =================================response================================= */
 
 func (g *GinActionImpl) returnJsonWithStatusOK() {
+	g.res.Message = Translate(g.locale, g.res.Message)
+	g.res.RequestID = responseRequestID(g.c)
 	g.c.AbortWithStatusJSON(http.StatusOK, g.res)
 }
 
 func (g *GinActionImpl) returnJsonWithStatusBadRequest() {
+	g.res.Message = Translate(g.locale, g.res.Message)
+	g.res.RequestID = responseRequestID(g.c)
 	g.c.AbortWithStatusJSON(http.StatusBadRequest, g.res)
 }
 
 // ThrowError 抛出错误
 func (g *GinActionImpl) ThrowError(err *ErrorModel) {
 
-	g.c.AbortWithStatusJSON(err.HttpStatus, NewResponse(
+	res := NewResponse(
 		err.Code,
-		err.Message,
+		TranslateError(g.locale, err),
 		err.Result,
-	))
+	)
+	res.RequestID = responseRequestID(g.c)
+	g.c.AbortWithStatusJSON(err.HttpStatus, res)
 }
 
 // Error 失败
@@ -134,6 +141,26 @@ func (g *GinActionImpl) BindParam(param interface{}) error {
 	return nil
 }
 
+// BindParamWithScene 绑定参数并使用场景校验 场景使用 Validate.RegisterScene 注册
+func (g *GinActionImpl) BindParamWithScene(param any, scene string) error {
+	//	 判断入参是否为指针是否为空
+	if param == nil {
+		panic("绑定参数不能为空")
+	}
+	//	 是否是指针
+	if reflect.TypeOf(param).Kind() != reflect.Ptr {
+		panic("绑定参数必须为指针")
+	}
+	//	 绑定参数
+	err := NewValidate().WithScene(param, scene, func() error {
+		return g.c.ShouldBind(param)
+	})
+	if err != nil {
+		return g.req.GetValidateErr(err, param)
+	}
+	return nil
+}
+
 // BindUriParam 绑定uri参数
 func (g *GinActionImpl) BindUriParam(param interface{}) error {
 	//	 判断入参是否为指针是否为空
@@ -210,8 +237,14 @@ func (g *GinActionImpl) Bind(param any, opts ...BindOption) error {
 }
 
 func NewGinActionImpl(c *gin.Context) *GinActionImpl {
+	locale := GetLocale(c)
+	opts := []RequestOption{WithLocale(locale)}
+	if c.GetBool(allErrorsKey) {
+		opts = append(opts, WithAllErrors())
+	}
 	return &GinActionImpl{
-		c:   c,
-		req: NewRequest(),
+		c:      c,
+		req:    NewRequest(opts...),
+		locale: locale,
 	}
 }
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type ginContextKey struct{}
+
+// GinContext 从 Handle 传入的ctx中获取gin的上下文
+func GinContext(ctx context.Context) (*gin.Context, bool) {
+	c, ok := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c, ok
+}
+
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	scene string
+}
+
+// WithBindScene 绑定请求参数时使用场景校验 场景使用 Validate.RegisterScene 注册
+func WithBindScene(scene string) HandleOption {
+	return func(o *handleOptions) {
+		o.scene = scene
+	}
+}
+
+// Handle 把业务函数转换为gin的处理函数
+// 请求参数依次从uri参数、请求头、请求体或查询参数绑定到Req 全部绑定后统一校验 校验失败返回 Request.GetValidateErr 的错误
+// fn 返回的错误为 *ErrorModel 时按其状态码返回 其他错误记录到 c.Errors 由 AccessLog 输出 返回 ServerError
+// 成功时使用 Success 返回Resp
+// ctx 为请求的ctx 可以使用 GinContext 获取gin的上下文
+func Handle[Req, Resp any](fn func(ctx context.Context, req *Req) (Resp, error), opts ...HandleOption) gin.HandlerFunc {
+	o := &handleOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		if describe[Req, Resp](c) {
+			return
+		}
+		action := NewGinActionImpl(c)
+		req := new(Req)
+		var err error
+		if o.scene != "" {
+			err = NewValidate().WithScene(req, o.scene, func() error {
+				return bindRequest(c, req)
+			})
+		} else {
+			err = bindRequest(c, req)
+		}
+		if err != nil {
+			action.ThrowError(action.req.GetValidateErr(err, req))
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		resp, err := fn(ctx, req)
+		if err != nil {
+			var model *ErrorModel
+			// 返回值为nil的 *ErrorModel 时 err 不为nil 但转换后的错误为nil
+			if errors.As(err, &model) && model != nil {
+				action.ThrowError(model)
+				return
+			}
+			// 其他错误可能包含数据库等内部信息 只记录到访问日志 不返回给客户端
+			_ = c.Error(err)
+			action.ThrowError(ServerError)
+			return
+		}
+		action.Success(resp)
+	}
+}
+
+// bindRequest 绑定所有来源的参数 最后由请求体或查询参数的绑定统一校验
+func bindRequest(c *gin.Context, obj any) error {
+	if len(c.Params) > 0 {
+		params := make(map[string][]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = []string{p.Value}
+		}
+		if err := binding.MapFormWithTag(obj, params, "uri"); err != nil {
+			return err
+		}
+	}
+	if hasTag(reflect.TypeOf(obj), "header") {
+		// header标签可以使用任意大小写
+		headers := make(map[string][]string, len(c.Request.Header)*2)
+		for k, v := range c.Request.Header {
+			headers[k] = v
+			headers[strings.ToLower(k)] = v
+		}
+		if err := binding.MapFormWithTag(obj, headers, "header"); err != nil {
+			return err
+		}
+	}
+	b := binding.Default(c.Request.Method, c.ContentType())
+	// 没有请求体时只绑定查询参数
+	if c.Request.ContentLength == 0 && c.Request.Method != http.MethodGet {
+		b = binding.Query
+	}
+	return c.ShouldBindWith(obj, b)
+}
+
+type tagKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var tagCache sync.Map
+
+// hasTag 判断结构体是否有字段使用了tag 包括嵌入的结构体
+func hasTag(t reflect.Type, tag string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	key := tagKey{t, tag}
+	if v, ok := tagCache.Load(key); ok {
+		return v.(bool)
+	}
+	found := false
+	for i := 0; i < t.NumField() && !found; i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup(tag); ok {
+			found = true
+		} else if f.Anonymous {
+			found = hasTag(f.Type, tag)
+		}
+	}
+	tagCache.Store(key, found)
+	return found
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type handleReq struct {
+	ID     int    `uri:"id" binding:"required"`
+	Tenant string `header:"X-Tenant"`
+	Page   int    `form:"page"`
+	Name   string `json:"name" form:"name" binding:"required"`
+}
+
+func newHandleEngine(fn func(ctx context.Context, req *handleReq) (*handleReq, error)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Any("/items/:id", Handle(fn))
+	return engine
+}
+
+func serve(engine http.Handler, req *http.Request) (*httptest.ResponseRecorder, *Response) {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	res := &Response{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	return w, res
+}
+
+func TestHandleBind(t *testing.T) {
+	engine := newHandleEngine(func(ctx context.Context, req *handleReq) (*handleReq, error) {
+		if _, ok := GinContext(ctx); !ok {
+			return nil, errors.New("gin context missing")
+		}
+		return req, nil
+	})
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   handleReq
+	}{
+		{"json body", http.MethodPost, "/items/7?page=3", `{"name":"a"}`, handleReq{ID: 7, Tenant: "t1", Name: "a"}},
+		{"get query", http.MethodGet, "/items/7?page=2&name=b", "", handleReq{ID: 7, Tenant: "t1", Page: 2, Name: "b"}},
+		{"empty body uses query", http.MethodDelete, "/items/8?page=1&name=c", "", handleReq{ID: 8, Tenant: "t1", Page: 1, Name: "c"}},
+		{"empty patch uses query", http.MethodPatch, "/items/9?page=4&name=d", "", handleReq{ID: 9, Tenant: "t1", Page: 4, Name: "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = http.NoBody
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.target, body)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("x-tenant", "t1")
+			w, res := serve(engine, req)
+			if w.Code != http.StatusOK || res.Code != SUCCESS {
+				t.Fatalf("status = %d %s", w.Code, w.Body.String())
+			}
+			got := handleReq{}
+			data, _ := json.Marshal(res.Result)
+			_ = json.Unmarshal(data, &got)
+			if got != tt.want {
+				t.Errorf("req = %+v", got)
+			}
+		})
+	}
+}
+
+func TestHandleValidate(t *testing.T) {
+	called := false
+	engine := newHandleEngine(func(ctx context.Context, req *handleReq) (*handleReq, error) {
+		called = true
+		return req, nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/items/1", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w, res := serve(engine, req)
+	if called || w.Code != http.StatusPreconditionFailed || res.Code != ERROR || !strings.Contains(res.Message, "name") {
+		t.Errorf("validate = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	var nilModel *ErrorModel
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   int
+	}{
+		{"error model", Forbidden, http.StatusForbidden, Forbidden.Code},
+		{"wrapped error model", errors.Join(errors.New("denied"), TooManyRequests), http.StatusTooManyRequests, TooManyRequests.Code},
+		{"plain error", errors.New("boom"), http.StatusInternalServerError, ServerError.Code},
+		{"typed nil error model", nilModel, http.StatusInternalServerError, ServerError.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newHandleEngine(func(ctx context.Context, req *handleReq) (*handleReq, error) {
+				return nil, tt.err
+			})
+			w, res := serve(engine, httptest.NewRequest(http.MethodGet, "/items/1?name=a", nil))
+			if w.Code != tt.status || res.Code != tt.code {
+				t.Errorf("error = %d %s", w.Code, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "boom") {
+				t.Errorf("internal error leaked: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleErrorLogged(t *testing.T) {
+	var buf bytes.Buffer
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(AccessLog(WithAccessLogOutput(&buf)))
+	engine.GET("/items/:id", Handle(func(ctx context.Context, req *handleReq) (*handleReq, error) {
+		return nil, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	}))
+	w, res := serve(engine, httptest.NewRequest(http.MethodGet, "/items/1?name=a", nil))
+	if res.Message != ServerError.Message || strings.Contains(w.Body.String(), "3306") {
+		t.Errorf("response = %s", w.Body.String())
+	}
+	// 原始错误只出现在日志中
+	if !strings.Contains(buf.String(), "connection refused") {
+		t.Errorf("log = %s", buf.String())
+	}
+}
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+
+	// localeKey gin上下文中保存当前请求语言的key
+	localeKey = "web.locale"
+)
+
+// DefaultLocale 未协商出语言时使用的语言 框架内置的文本均为中文
+var DefaultLocale = LocaleZh
+
+// catalog 消息目录 按语言保存 原始文本->翻译 和 错误码->翻译
+type catalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string
+	codes    map[string]map[int]string
+
+	// list 缓存的 locales 结果 注册消息或校验翻译时清空 version 用于丢弃计算期间失效的结果
+	list        []string
+	listDefault string
+	version     int
+}
+
+var messages = &catalog{
+	messages: map[string]map[string]string{
+		LocaleEn: {
+			Succeed:       "Success",
+			CreateSuccess: "Created successfully",
+			UpdateSuccess: "Updated successfully",
+			DeleteSuccess: "Deleted successfully",
+			GetSuccess:    "Fetched successfully",
+			OkSuccess:     "Operation successful",
+			"未知错误":        "Unknown error",
+		},
+	},
+	codes: map[string]map[int]string{
+		LocaleEn: {
+			500:   "Server error",
+			10000: "Not logged in",
+			10001: "Invalid token",
+			10002: "Token expired",
+			10003: "Incorrect username or password",
+			10004: "Platform does not exist",
+			10005: "Platform id cannot be empty",
+			10006: "Access denied",
+			10007: "Too many requests",
+			10008: "A request with the same idempotency key is in progress",
+			10009: "Idempotency key was used for a different request",
+			10010: "Idempotency key is required",
+			10011: "Idempotency key is invalid",
+			10012: "Request entity too large",
+		},
+	},
+}
+
+// RegisterMessages 注册一种语言的消息翻译 key为原始文本 例如 web.CreateSuccess
+func RegisterMessages(locale string, m map[string]string) {
+	messages.mu.Lock()
+	defer messages.mu.Unlock()
+	messages.invalidate()
+	if messages.messages[locale] == nil {
+		messages.messages[locale] = make(map[string]string)
+	}
+	for k, v := range m {
+		messages.messages[locale][k] = v
+	}
+}
+
+// RegisterCodeMessages 注册一种语言的框架预定义错误的翻译 key为错误码 例如 web.Forbidden.Code
+// 只用于 ServerError 等框架预定义的错误 业务错误的错误码可能与其重复 需要使用 RegisterMessages 按原始文本翻译
+func RegisterCodeMessages(locale string, m map[int]string) {
+	messages.mu.Lock()
+	defer messages.mu.Unlock()
+	messages.invalidate()
+	if messages.codes[locale] == nil {
+		messages.codes[locale] = make(map[int]string)
+	}
+	for k, v := range m {
+		messages.codes[locale][k] = v
+	}
+}
+
+// Translate 翻译消息 没有翻译时返回原始文本
+func Translate(locale, message string) string {
+	messages.mu.RLock()
+	defer messages.mu.RUnlock()
+	if v, ok := messages.messages[locale][message]; ok {
+		return v
+	}
+	return message
+}
+
+// TranslateError 翻译错误信息 优先按原始文本翻译 框架预定义的错误其次按错误码翻译
+func TranslateError(locale string, err *ErrorModel) string {
+	messages.mu.RLock()
+	defer messages.mu.RUnlock()
+	if v, ok := messages.messages[locale][err.Message]; ok {
+		return v
+	}
+	if predefined(err) {
+		if v, ok := messages.codes[locale][err.Code]; ok {
+			return v
+		}
+	}
+	return err.Message
+}
+
+// predefinedErrors 框架预定义的错误 按错误码翻译
+var predefinedErrors = []*ErrorModel{
+	ServerError,
+	UNAUTHORIZED,
+	InvalidToken,
+	TokenExpired,
+	UsernameOrPasswordError,
+	PlatformNotExist,
+	PlatformIdCanNotEmpty,
+	Forbidden,
+	TooManyRequests,
+	IdempotencyKeyInProgress,
+	IdempotencyKeyMismatch,
+	IdempotencyKeyRequired,
+	IdempotencyKeyInvalid,
+	RequestEntityTooLarge,
+}
+
+// predefined 是否为框架预定义的错误 错误码和原始文本都相同的副本也视为预定义的错误 例如修改了 Result 的副本
+func predefined(err *ErrorModel) bool {
+	for _, e := range predefinedErrors {
+		if e == err || (e.Code == err.Code && e.Message == err.Message) {
+			return true
+		}
+	}
+	return false
+}
+
+// locales 所有注册了消息或校验翻译的语言 结果会被缓存 调用方不能修改
+func (c *catalog) locales() []string {
+	c.mu.RLock()
+	list, def, version := c.list, c.listDefault, c.version
+	c.mu.RUnlock()
+	if list != nil && def == DefaultLocale {
+		return list
+	}
+
+	// NewValidate 第一次调用时会注册翻译并清空缓存 不能在持有锁时调用
+	validateLocales := NewValidate().Locales()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set := map[string]struct{}{DefaultLocale: {}}
+	for l := range c.messages {
+		set[l] = struct{}{}
+	}
+	for l := range c.codes {
+		set[l] = struct{}{}
+	}
+	for _, l := range validateLocales {
+		set[l] = struct{}{}
+	}
+	result := make([]string, 0, len(set))
+	for l := range set {
+		result = append(result, l)
+	}
+	sort.Strings(result)
+	if c.version == version {
+		c.list, c.listDefault = result, DefaultLocale
+	}
+	return result
+}
+
+// invalidate 清空 locales 的缓存 调用时需要持有 c.mu
+func (c *catalog) invalidate() {
+	c.list = nil
+	c.version++
+}
+
+type LocaleOption func(*localeOptions)
+
+type localeOptions struct {
+	queryKey string
+}
+
+// WithLocaleQuery 设置指定语言的查询参数名 默认为 lang 设置为空时只使用 Accept-Language
+func WithLocaleQuery(key string) LocaleOption {
+	return func(o *localeOptions) {
+		o.queryKey = key
+	}
+}
+
+// Locale 语言协商中间件 优先使用查询参数 其次使用 Accept-Language 请求头 都不支持时使用 DefaultLocale
+// 之后的 GinActionImpl 和 Handle 使用协商出的语言翻译响应消息和校验错误
+func Locale(opts ...LocaleOption) gin.HandlerFunc {
+	o := &localeOptions{queryKey: "lang"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		supported := messages.locales()
+		locale := ""
+		if o.queryKey != "" {
+			locale = matchLocale(supported, c.Query(o.queryKey))
+		}
+		if locale == "" {
+			for _, tag := range parseAcceptLanguage(c.GetHeader("Accept-Language")) {
+				if locale = matchLocale(supported, tag); locale != "" {
+					break
+				}
+			}
+		}
+		if locale == "" {
+			locale = DefaultLocale
+		}
+		c.Set(localeKey, locale)
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
+
+// GetLocale 获取当前请求的语言 没有使用 Locale 中间件时返回 DefaultLocale
+func GetLocale(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// matchLocale 匹配支持的语言 先完整匹配 再按主语言匹配 例如 zh-CN 匹配 zh
+func matchLocale(supported []string, tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return ""
+	}
+	base := strings.Split(tag, "-")[0]
+	match := ""
+	for _, l := range supported {
+		switch strings.ToLower(strings.ReplaceAll(l, "_", "-")) {
+		case tag:
+			return l
+		case base:
+			match = l
+		}
+	}
+	return match
+}
+
+// parseAcceptLanguage 解析 Accept-Language 按权重从高到低返回语言
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		value string
+		q     float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if value == "" || value == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{value, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.value
+	}
+	return result
+}
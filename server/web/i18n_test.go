@@ -0,0 +1,137 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en", []string{"en"}},
+		{"zh-CN,zh;q=0.9,en;q=0.8", []string{"zh-CN", "zh", "en"}},
+		{"en;q=0.5, fr;q=0.9, de", []string{"de", "fr", "en"}},
+		{"ja;q=0, *;q=0.1, en;q=bad", []string{"en"}},
+		{"fr;q=0.8, it;q=0.8", []string{"fr", "it"}},
+	}
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %v; want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	supported := []string{"en", "pt_BR", "zh"}
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"en", "en"},
+		{"EN-us", "en"},
+		{"zh-Hans-CN", "zh"},
+		{"pt-BR", "pt_BR"},
+		{"pt", ""},
+		{"fr", ""},
+		{" ", ""},
+	}
+	for _, tt := range tests {
+		if got := matchLocale(supported, tt.tag); got != tt.want {
+			t.Errorf("matchLocale(%q) = %q; want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Locale())
+	engine.GET("/", func(c *gin.Context) {
+		NewGinActionImpl(c).ThrowError(Forbidden)
+	})
+	engine.GET("/ok", func(c *gin.Context) {
+		NewGinActionImpl(c).CreateOK()
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		locale   string
+		message  string
+		httpCode int
+	}{
+		{"default", "/", "", LocaleZh, Forbidden.Message, http.StatusForbidden},
+		{"q value", "/", "fr;q=1, en-US;q=0.8, zh;q=0.5", LocaleEn, "Access denied", http.StatusForbidden},
+		{"query overrides header", "/?lang=zh", "en", LocaleZh, Forbidden.Message, http.StatusForbidden},
+		{"unsupported", "/", "fr", LocaleZh, Forbidden.Message, http.StatusForbidden},
+		{"message catalog", "/ok", "en-GB", LocaleEn, "Created successfully", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			w, res := serve(engine, req)
+			if w.Code != tt.httpCode || w.Header().Get("Content-Language") != tt.locale || res.Message != tt.message {
+				t.Errorf("got %d %s %s", w.Code, w.Header().Get("Content-Language"), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	custom := NewErrorModel(20001, "库存不足", nil, http.StatusConflict)
+	RegisterCodeMessages(LocaleEn, map[int]string{20001: "Code message"})
+	// 业务错误不按错误码翻译
+	if got := TranslateError(LocaleEn, custom); got != custom.Message {
+		t.Errorf("code message = %q", got)
+	}
+	RegisterMessages(LocaleEn, map[string]string{"库存不足": "Out of stock"})
+	if got := TranslateError(LocaleEn, custom); got != "Out of stock" {
+		t.Errorf("text message = %q", got)
+	}
+	if got := TranslateError(LocaleZh, custom); got != custom.Message {
+		t.Errorf("zh message = %q", got)
+	}
+	// 与预定义错误的错误码相同的业务错误保留自己的文本
+	if got := TranslateError(LocaleEn, NewErrorModel(Forbidden.Code, "只能修改自己的订单", nil, http.StatusForbidden)); got != "只能修改自己的订单" {
+		t.Errorf("colliding code = %q", got)
+	}
+	copied := *Forbidden
+	copied.Result = gin.H{"permission": "order:write"}
+	if got := TranslateError(LocaleEn, &copied); got != "Access denied" {
+		t.Errorf("copy of predefined = %q", got)
+	}
+	// ERROR 错误码的错误只按原始文本翻译
+	if got := TranslateError(LocaleEn, NewErrorModel(ERROR, "未知错误", nil, http.StatusBadRequest)); got != "Unknown error" {
+		t.Errorf("text message = %q", got)
+	}
+	for _, model := range []*ErrorModel{ServerError, UNAUTHORIZED, Forbidden, TooManyRequests, IdempotencyKeyInvalid, RequestEntityTooLarge} {
+		if TranslateError(LocaleEn, model) == model.Message {
+			t.Errorf("%d has no en translation", model.Code)
+		}
+	}
+}
+
+func TestLocalesCache(t *testing.T) {
+	// 第一次调用会初始化校验翻译 结果不会被缓存
+	messages.locales()
+	first := messages.locales()
+	if again := messages.locales(); &again[0] != &first[0] {
+		t.Error("locales were not cached")
+	}
+	RegisterMessages("ja", map[string]string{Succeed: "成功しました"})
+	if got := messages.locales(); !slices.Contains(got, "ja") {
+		t.Errorf("locales = %v", got)
+	}
+}
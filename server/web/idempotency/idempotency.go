@@ -0,0 +1,261 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+const (
+	// DefaultHeader 幂等键的默认请求头
+	DefaultHeader = "Idempotency-Key"
+	// ReplayedHeader 重放保存的响应时添加的响应头
+	ReplayedHeader = "Idempotent-Replayed"
+	// maxKeyLength 幂等键的最大长度
+	maxKeyLength = 255
+	// DefaultMaxBodySize 计算哈希时读取请求体的默认最大长度
+	DefaultMaxBodySize = 1 << 20
+)
+
+// Record 幂等键对应的请求记录 Done 为false时表示第一次请求正在处理中
+type Record struct {
+	// Token 加锁的请求的随机标识 只有持有锁的请求可以保存或删除记录
+	Token       string
+	Hash        string
+	Done        bool
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store 幂等记录的存储
+type Store interface {
+	// Lock 保存处理中的记录并加锁 key已存在时返回已有的记录和false
+	Lock(ctx context.Context, key string, rec *Record, ttl time.Duration) (*Record, bool, error)
+	// Save 保存请求的结果 记录的 Token 和加锁时不同时不保存
+	Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Unlock 删除处理中的记录 用于请求失败后允许客户端重试
+	Unlock(ctx context.Context, key string, token string) error
+}
+
+type Option func(*options)
+
+type options struct {
+	header      string
+	ttl         time.Duration
+	lockTimeout time.Duration
+	methods     map[string]bool
+	required    bool
+	maxBodySize int64
+	scope       func(c *gin.Context) string
+}
+
+// WithHeader 设置幂等键的请求头 默认为 Idempotency-Key
+func WithHeader(header string) Option {
+	return func(o *options) {
+		o.header = header
+	}
+}
+
+// WithTTL 设置保存请求结果的时间 默认为24小时
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithLockTimeout 设置处理中记录的过期时间 应大于接口的最长处理时间 默认为1分钟
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = d
+	}
+}
+
+// WithMethods 设置需要处理幂等键的请求方法 默认为 POST 和 PATCH
+func WithMethods(methods ...string) Option {
+	return func(o *options) {
+		o.methods = make(map[string]bool, len(methods))
+		for _, m := range methods {
+			o.methods[strings.ToUpper(m)] = true
+		}
+	}
+}
+
+// WithRequired 要求请求必须带有幂等键 没有时返回 web.IdempotencyKeyRequired
+func WithRequired() Option {
+	return func(o *options) {
+		o.required = true
+	}
+}
+
+// WithMaxBodySize 设置带有幂等键的请求体的最大长度 超过时返回 web.RequestEntityTooLarge 默认为1MB
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+// WithScope 设置幂等键的作用范围 不同范围的相同幂等键互不影响
+// 默认按 web.GetUserID 保存的用户区分 没有用户时按客户端ip区分
+func WithScope(fn func(c *gin.Context) string) Option {
+	return func(o *options) {
+		o.scope = fn
+	}
+}
+
+// Middleware 幂等中间件 需要在认证中间件之后使用
+// 第一次请求执行时加锁 完成后保存状态码和响应 之后相同幂等键和相同参数的请求直接返回保存的响应
+// 第一次请求未完成时返回 web.IdempotencyKeyInProgress 参数不同时返回 web.IdempotencyKeyMismatch
+// 返回5xx、429或发生panic时删除记录 客户端可以使用相同的幂等键重试
+func Middleware(store Store, opts ...Option) gin.HandlerFunc {
+	o := &options{
+		header:      DefaultHeader,
+		ttl:         24 * time.Hour,
+		lockTimeout: time.Minute,
+		methods:     map[string]bool{http.MethodPost: true, http.MethodPatch: true},
+		maxBodySize: DefaultMaxBodySize,
+		scope:       defaultScope,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		if !o.methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(o.header)
+		if key == "" {
+			if o.required {
+				web.NewGinActionImpl(c).ThrowError(web.IdempotencyKeyRequired)
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			web.NewGinActionImpl(c).ThrowError(web.IdempotencyKeyInvalid)
+			return
+		}
+
+		hash, err := requestHash(c, o.maxBodySize)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				web.NewGinActionImpl(c).ThrowError(web.RequestEntityTooLarge)
+				return
+			}
+			_ = c.Error(err)
+			web.NewGinActionImpl(c).ThrowError(web.ServerError)
+			return
+		}
+		key = o.scope(c) + ":" + key
+		ctx := c.Request.Context()
+		lock := &Record{Token: newToken(), Hash: hash}
+		rec, acquired, err := store.Lock(ctx, key, lock, o.lockTimeout)
+		if err != nil {
+			_ = c.Error(err)
+			web.NewGinActionImpl(c).ThrowError(web.ServerError)
+			return
+		}
+		if !acquired {
+			replay(c, rec, hash)
+			return
+		}
+
+		w := &recorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		completed := false
+		defer func() {
+			c.Writer = w.ResponseWriter
+			// 使用新的ctx 请求取消后仍然需要保存或释放记录
+			ctx := context.WithoutCancel(ctx)
+			status := w.Status()
+			if !completed || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+				if err := store.Unlock(ctx, key, lock.Token); err != nil {
+					_ = c.Error(err)
+				}
+				return
+			}
+			lock.Done = true
+			lock.Status = status
+			lock.ContentType = w.Header().Get("Content-Type")
+			lock.Body = w.body.Bytes()
+			if err := store.Save(ctx, key, lock, o.ttl); err != nil {
+				_ = c.Error(err)
+			}
+		}()
+		c.Next()
+		completed = true
+	}
+}
+
+// defaultScope 已登录的请求按用户区分 匿名请求按客户端ip区分
+func defaultScope(c *gin.Context) string {
+	if id := web.GetUserID(c); id != "" {
+		return "user:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// replay 处理已存在记录的请求
+func replay(c *gin.Context, rec *Record, hash string) {
+	switch {
+	case rec.Hash != hash:
+		web.NewGinActionImpl(c).ThrowError(web.IdempotencyKeyMismatch)
+	case !rec.Done:
+		c.Header("Retry-After", "1")
+		web.NewGinActionImpl(c).ThrowError(web.IdempotencyKeyInProgress)
+	default:
+		c.Header(ReplayedHeader, "true")
+		c.Header("Content-Length", strconv.Itoa(len(rec.Body)))
+		c.Data(rec.Status, rec.ContentType, rec.Body)
+		c.Abort()
+	}
+}
+
+// requestHash 计算请求的方法、路径、查询参数和请求体的哈希 读取后恢复请求体
+// 请求体超过 maxBodySize 时返回 *http.MaxBytesError maxBodySize 小于等于0时不限制
+func requestHash(c *gin.Context, maxBodySize int64) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "\n"))
+	if c.Request.Body != nil {
+		reader := c.Request.Body
+		if maxBodySize > 0 {
+			reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recorder 记录响应体
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
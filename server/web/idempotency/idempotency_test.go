@@ -0,0 +1,187 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+func newEngine(store Store, created *atomic.Int32, opts ...Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Middleware(store, opts...))
+	engine.POST("/orders", func(c *gin.Context) {
+		n := created.Add(1)
+		web.NewGinActionImpl(c).Success(gin.H{"id": n})
+	})
+	engine.POST("/fail", func(c *gin.Context) {
+		created.Add(1)
+		web.NewGinActionImpl(c).ThrowError(web.ServerError)
+	})
+	return engine
+}
+
+func post(engine *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(DefaultHeader, key)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// stores 使用相同的用例测试内存和redis存储
+func stores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  newRedisStore(t),
+	}
+}
+
+func TestReplay(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testReplay(t, store)
+		})
+	}
+}
+
+func testReplay(t *testing.T, store Store) {
+	var created atomic.Int32
+	engine := newEngine(store, &created)
+
+	first := post(engine, "/orders", "k1", `{"amount":1}`)
+	second := post(engine, "/orders", "k1", `{"amount":1}`)
+	if created.Load() != 1 {
+		t.Fatalf("created = %d", created.Load())
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() || second.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("replay = %d %s", second.Code, second.Body.String())
+	}
+
+	w := post(engine, "/orders", "k1", `{"amount":2}`)
+	res := &web.Response{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	if w.Code != http.StatusUnprocessableEntity || res.Code != web.IdempotencyKeyMismatch.Code {
+		t.Errorf("mismatch = %d %s", w.Code, w.Body.String())
+	}
+
+	post(engine, "/orders", "", `{"amount":1}`)
+	post(engine, "/orders", "k2", `{"amount":1}`)
+	if created.Load() != 3 {
+		t.Errorf("created = %d", created.Load())
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testInProgress(t, store)
+		})
+	}
+}
+
+func testInProgress(t *testing.T, store Store) {
+	var created atomic.Int32
+	engine := newEngine(store, &created)
+
+	// 模拟另一个实例正在处理相同幂等键的请求
+	hashReq := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = hashReq
+	hash, _ := requestHash(c, DefaultMaxBodySize)
+	if _, ok, _ := store.Lock(hashReq.Context(), "ip:192.0.2.1:k1", &Record{Token: "other", Hash: hash}, time.Minute); !ok {
+		t.Fatal("lock failed")
+	}
+
+	w := post(engine, "/orders", "k1", `{}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" || created.Load() != 0 {
+		t.Errorf("in progress = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFailureAllowsRetry(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testFailureAllowsRetry(t, store)
+		})
+	}
+}
+
+func testFailureAllowsRetry(t *testing.T, store Store) {
+	var created atomic.Int32
+	engine := newEngine(store, &created, WithRequired())
+
+	post(engine, "/fail", "k1", `{}`)
+	post(engine, "/fail", "k1", `{}`)
+	if created.Load() != 2 {
+		t.Errorf("created = %d", created.Load())
+	}
+	if w := post(engine, "/orders", "", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing key = %d", w.Code)
+	}
+}
+
+func TestScope(t *testing.T) {
+	var created atomic.Int32
+	engine := newEngine(NewMemoryStore(), &created)
+
+	// 匿名请求按客户端ip区分
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set(DefaultHeader, "k1")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if created.Load() != 2 {
+		t.Errorf("created = %d", created.Load())
+	}
+
+	// 登录的用户按用户id区分
+	users := gin.New()
+	users.Use(func(c *gin.Context) {
+		web.SetUserID(c, c.GetHeader("X-User"))
+	}, Middleware(NewMemoryStore()))
+	users.POST("/orders", func(c *gin.Context) {
+		created.Add(1)
+		web.NewGinActionImpl(c).Success(nil)
+	})
+	for _, user := range []string{"1", "2", "1"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("X-User", user)
+		req.Header.Set(DefaultHeader, "k1")
+		users.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if created.Load() != 4 {
+		t.Errorf("created = %d", created.Load())
+	}
+}
+
+func TestInvalidRequest(t *testing.T) {
+	var created atomic.Int32
+	engine := newEngine(NewMemoryStore(), &created, WithMaxBodySize(16))
+
+	w := post(engine, "/orders", strings.Repeat("k", maxKeyLength+1), `{}`)
+	res := &web.Response{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	if w.Code != http.StatusBadRequest || res.Code != web.IdempotencyKeyInvalid.Code {
+		t.Errorf("long key = %d %s", w.Code, w.Body.String())
+	}
+
+	w = post(engine, "/orders", "k1", strings.Repeat("a", 17))
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	if w.Code != http.StatusRequestEntityTooLarge || res.Code != web.RequestEntityTooLarge.Code {
+		t.Errorf("large body = %d %s", w.Code, w.Body.String())
+	}
+	if created.Load() != 0 {
+		t.Errorf("created = %d", created.Load())
+	}
+}
@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix redis key的默认前缀
+const DefaultPrefix = "idempotency:"
+
+// 记录保存在hash中 字段为 token hash done status content_type body
+
+// lockScript key不存在时保存处理中的记录 存在时返回已有的记录
+// KEYS: 记录 ARGV: token 请求哈希 过期毫秒数
+var lockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HMGET', KEYS[1], 'token', 'hash', 'done', 'status', 'content_type', 'body')
+end
+redis.call('HSET', KEYS[1], 'token', ARGV[1], 'hash', ARGV[2], 'done', '0')
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return false
+`)
+
+// saveScript 持有锁时保存请求的结果
+// KEYS: 记录 ARGV: token 状态码 Content-Type 响应体 过期毫秒数
+var saveScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'done', '1', 'status', ARGV[2], 'content_type', ARGV[3], 'body', ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// unlockScript 持有锁且请求未完成时删除记录
+// KEYS: 记录 ARGV: token
+var unlockScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') == ARGV[1] and redis.call('HGET', KEYS[1], 'done') == '0' then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore 使用redis保存幂等记录 用于多实例部署
+type RedisStore struct {
+	Client redis.UniversalClient
+	Prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{
+		Client: client,
+		Prefix: DefaultPrefix,
+	}
+}
+
+// Lock 实现 Store
+func (s *RedisStore) Lock(ctx context.Context, key string, rec *Record, ttl time.Duration) (*Record, bool, error) {
+	res, err := lockScript.Run(ctx, s.Client, []string{s.Prefix + key}, rec.Token, rec.Hash, ttl.Milliseconds()).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	existing := &Record{}
+	existing.Token, _ = res[0].(string)
+	existing.Hash, _ = res[1].(string)
+	done, _ := res[2].(string)
+	existing.Done = done == "1"
+	status, _ := res[3].(string)
+	existing.Status, _ = strconv.Atoi(status)
+	existing.ContentType, _ = res[4].(string)
+	body, _ := res[5].(string)
+	existing.Body = []byte(body)
+	return existing, false, nil
+}
+
+// Save 实现 Store
+func (s *RedisStore) Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	return saveScript.Run(ctx, s.Client, []string{s.Prefix + key},
+		rec.Token, rec.Status, rec.ContentType, rec.Body, ttl.Milliseconds(),
+	).Err()
+}
+
+// Unlock 实现 Store
+func (s *RedisStore) Unlock(ctx context.Context, key string, token string) error {
+	return unlockScript.Run(ctx, s.Client, []string{s.Prefix + key}, token).Err()
+}
+
+// HealthCheck ping redis 实现 health.HealthChecker
+func (s *RedisStore) HealthCheck(ctx context.Context) error {
+	return s.Client.Ping(ctx).Err()
+}
+
+// MemoryStore 单实例使用的内存存储
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryRecord
+	ops     int
+}
+
+type memoryRecord struct {
+	Record
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord)}
+}
+
+// Lock 实现 Store 每1024次调用清理一次过期的记录
+func (s *MemoryStore) Lock(ctx context.Context, key string, rec *Record, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ops++; s.ops%1024 == 0 {
+		for k, r := range s.records {
+			if now.After(r.expires) {
+				delete(s.records, k)
+			}
+		}
+	}
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		existing := r.Record
+		return &existing, false, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Token: rec.Token, Hash: rec.Hash}, expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Save 实现 Store
+func (s *MemoryStore) Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && r.Token == rec.Token {
+		s.records[key] = &memoryRecord{Record: *rec, expires: time.Now().Add(ttl)}
+	}
+	return nil
+}
+
+// Unlock 实现 Store
+func (s *MemoryStore) Unlock(ctx context.Context, key string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && r.Token == token && !r.Done {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,132 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisStore(client), mr
+}
+
+func newRedisStore(t *testing.T) Store {
+	s, _ := newRedis(t)
+	return s
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newRedis(t)
+
+	if existing, ok, err := s.Lock(ctx, "k", &Record{Token: "a", Hash: "h1"}, time.Minute); err != nil || !ok || existing != nil {
+		t.Fatalf("lock = %+v, %v, %v", existing, ok, err)
+	}
+	if ttl := mr.TTL(DefaultPrefix + "k"); ttl != time.Minute {
+		t.Errorf("lock ttl = %v", ttl)
+	}
+
+	// 处理中的记录只有token和哈希
+	existing, ok, err := s.Lock(ctx, "k", &Record{Token: "b", Hash: "h2"}, time.Minute)
+	if err != nil || ok || existing == nil {
+		t.Fatalf("duplicate lock = %+v, %v, %v", existing, ok, err)
+	}
+	if existing.Token != "a" || existing.Hash != "h1" || existing.Done || existing.Status != 0 || len(existing.Body) != 0 {
+		t.Errorf("in progress = %+v", existing)
+	}
+
+	// 其他请求不能删除处理中的记录
+	if err := s.Unlock(ctx, "k", "b"); err != nil || !mr.Exists(DefaultPrefix+"k") {
+		t.Fatalf("unlock with other token = %v", err)
+	}
+
+	body := []byte("{\"id\":1}\x00\xff")
+	if err := s.Save(ctx, "k", &Record{Token: "a", Hash: "h1", Status: http.StatusCreated, ContentType: "application/json", Body: body}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(DefaultPrefix + "k"); ttl != time.Hour {
+		t.Errorf("save ttl = %v", ttl)
+	}
+	existing, ok, err = s.Lock(ctx, "k", &Record{Token: "c", Hash: "h1"}, time.Minute)
+	if err != nil || ok {
+		t.Fatalf("replay lock = %v, %v", ok, err)
+	}
+	if !existing.Done || existing.Token != "a" || existing.Status != http.StatusCreated ||
+		existing.ContentType != "application/json" || string(existing.Body) != string(body) {
+		t.Errorf("replay = %+v", existing)
+	}
+
+	// 完成的记录不能被删除
+	if err := s.Unlock(ctx, "k", "a"); err != nil || !mr.Exists(DefaultPrefix+"k") {
+		t.Errorf("unlock done = %v", err)
+	}
+}
+
+func TestRedisStoreUnlock(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newRedis(t)
+
+	if _, ok, _ := s.Lock(ctx, "k", &Record{Token: "a", Hash: "h"}, time.Minute); !ok {
+		t.Fatal("lock failed")
+	}
+	if err := s.Unlock(ctx, "k", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(DefaultPrefix + "k") {
+		t.Error("record was not deleted")
+	}
+	if _, ok, _ := s.Lock(ctx, "k", &Record{Token: "b", Hash: "h"}, time.Minute); !ok {
+		t.Error("lock after unlock failed")
+	}
+
+	// 请求返回5xx时删除记录 客户端可以重试
+	s, mr = newRedis(t)
+	var created atomic.Int32
+	engine := newEngine(s, &created)
+	if w := post(engine, "/fail", "k1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys after failure = %v", keys)
+	}
+}
+
+func TestRedisStoreStaleToken(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newRedis(t)
+
+	if _, ok, _ := s.Lock(ctx, "k", &Record{Token: "a", Hash: "h"}, time.Second); !ok {
+		t.Fatal("lock failed")
+	}
+	// 请求处理超过锁的过期时间 期间其他请求重新加锁
+	mr.FastForward(time.Second)
+	if err := s.Save(ctx, "k", &Record{Token: "a", Status: http.StatusOK, Body: []byte("late")}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(DefaultPrefix + "k") {
+		t.Fatal("expired lock was saved")
+	}
+
+	if _, ok, _ := s.Lock(ctx, "k", &Record{Token: "b", Hash: "h"}, time.Minute); !ok {
+		t.Fatal("relock failed")
+	}
+	if err := s.Save(ctx, "k", &Record{Token: "a", Status: http.StatusOK, Body: []byte("late")}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock(ctx, "k", "a"); err != nil {
+		t.Fatal(err)
+	}
+	existing, _, _ := s.Lock(ctx, "k", &Record{Token: "c", Hash: "h"}, time.Minute)
+	if existing == nil || existing.Token != "b" || existing.Done || len(existing.Body) != 0 {
+		t.Errorf("record = %+v", existing)
+	}
+}
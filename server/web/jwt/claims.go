@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// AccessToken 访问token 用于请求接口
+	AccessToken = "access"
+	// RefreshToken 刷新token 只能用于换取新的token
+	RefreshToken = "refresh"
+)
+
+// Audience aud 可以是字符串或字符串数组
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a Audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisteredClaims RFC 7519 中注册的声明 时间为unix秒
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Claims token中的声明 Data 为业务自定义的数据 例如用户名、平台id等
+type Claims[T any] struct {
+	RegisteredClaims
+	TokenType string `json:"token_type,omitempty"`
+	Data      T      `json:"data"`
+}
+
+// ExpiresTime token的过期时间
+func (c *RegisteredClaims) ExpiresTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
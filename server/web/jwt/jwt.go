@@ -0,0 +1,386 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenMissing 请求中没有token
+	ErrTokenMissing = errors.New("jwt: 缺少token")
+	// ErrInvalidToken token格式、签名或声明不正确
+	ErrInvalidToken = errors.New("jwt: 非法token")
+	// ErrTokenExpired token已过期
+	ErrTokenExpired = errors.New("jwt: token已过期")
+	// ErrTokenRevoked token已被吊销
+	ErrTokenRevoked = errors.New("jwt: token已吊销")
+	// ErrUnknownKey 没有token的kid对应的密钥
+	ErrUnknownKey = errors.New("jwt: 未知的密钥")
+	// ErrUnsupportedAlgorithm 不支持的签名算法
+	ErrUnsupportedAlgorithm = errors.New("jwt: 不支持的签名算法")
+	// ErrShortSecret HS256密钥长度不足
+	ErrShortSecret = errors.New("jwt: HS256密钥至少32字节")
+	// ErrNoStore 没有配置 RevocationStore 时不能刷新和吊销token
+	ErrNoStore = errors.New("jwt: 没有配置吊销记录存储")
+)
+
+var encoding = base64.RawURLEncoding
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// TokenPair 签发的访问token和刷新token
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at,omitempty"`
+}
+
+type Option func(*options)
+
+type options struct {
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	leeway     time.Duration
+	store      RevocationStore
+	verifyKeys []*Key
+	now        func() time.Time
+}
+
+// WithIssuer 设置签发者 签发时写入 iss 验证时要求 iss 一致
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = issuer
+	}
+}
+
+// WithAudience 设置接收方 签发时写入 aud 验证时要求 aud 包含此值
+func WithAudience(audience string) Option {
+	return func(o *options) {
+		o.audience = audience
+	}
+}
+
+// WithAccessTTL 设置访问token的有效期 默认为2小时
+func WithAccessTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.accessTTL = ttl
+	}
+}
+
+// WithRefreshTTL 设置刷新token的有效期 默认为7天
+func WithRefreshTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.refreshTTL = ttl
+	}
+}
+
+// WithLeeway 设置验证 exp 和 nbf 时允许的时钟偏差
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *options) {
+		o.leeway = leeway
+	}
+}
+
+// WithStore 设置吊销记录的存储 配置后才能刷新和吊销token 中间件会拒绝已吊销的token
+func WithStore(store RevocationStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithVerifyKeys 添加只用于验证的密钥 例如轮换前的旧密钥或其他服务的公钥
+func WithVerifyKeys(keys ...*Key) Option {
+	return func(o *options) {
+		o.verifyKeys = append(o.verifyKeys, keys...)
+	}
+}
+
+// Manager 签发和验证token T 为token中业务数据的类型
+type Manager[T any] struct {
+	options
+
+	mu         sync.RWMutex
+	signingKey *Key
+	keys       map[string]*Key
+}
+
+// New 创建token管理 key 为签名使用的密钥
+func New[T any](key *Key, opts ...Option) (*Manager[T], error) {
+	o := options{
+		accessTTL:  2 * time.Hour,
+		refreshTTL: 7 * 24 * time.Hour,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := &Manager[T]{options: o, keys: make(map[string]*Key)}
+	for _, k := range o.verifyKeys {
+		if err := m.AddKey(k); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Rotate(key); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Rotate 轮换签名密钥 之后签发的token使用新的密钥 旧的密钥继续用于验证直到调用 RemoveKey
+func (m *Manager[T]) Rotate(key *Key) error {
+	if key == nil || !key.canSign() {
+		return errors.New("jwt: 签名密钥不能为空且必须包含私钥")
+	}
+	if err := m.AddKey(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.signingKey = key
+	m.mu.Unlock()
+	return nil
+}
+
+// AddKey 添加验证密钥 HS256密钥少于32字节时返回 ErrShortSecret
+func (m *Manager[T]) AddKey(key *Key) error {
+	if key.Algorithm != HS256 && key.Algorithm != RS256 {
+		return ErrUnsupportedAlgorithm
+	}
+	if key.Algorithm == HS256 && len(key.secret) < minSecretLength {
+		return ErrShortSecret
+	}
+	m.mu.Lock()
+	m.keys[key.ID] = key
+	m.mu.Unlock()
+	return nil
+}
+
+// RemoveKey 删除验证密钥 使用此密钥签发的token将不能通过验证 不能删除当前的签名密钥
+func (m *Manager[T]) RemoveKey(kid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.signingKey != nil && m.signingKey.ID == kid {
+		return
+	}
+	delete(m.keys, kid)
+}
+
+// Issue 为subject签发访问token和刷新token 没有配置 RevocationStore 时只签发访问token
+func (m *Manager[T]) Issue(ctx context.Context, subject string, data T) (*TokenPair, error) {
+	now := m.now()
+	access, err := m.newClaims(subject, data, AccessToken, now, m.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	pair := &TokenPair{TokenType: "Bearer", ExpiresAt: access.ExpiresTime()}
+	if pair.AccessToken, err = m.Sign(access); err != nil {
+		return nil, err
+	}
+	if m.store == nil {
+		return pair, nil
+	}
+	refresh, err := m.newClaims(subject, data, RefreshToken, now, m.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = m.Sign(refresh); err != nil {
+		return nil, err
+	}
+	pair.RefreshExpiresAt = refresh.ExpiresTime()
+	return pair, nil
+}
+
+// Refresh 使用刷新token换取新的token 刷新token只能使用一次 重复使用返回 ErrTokenRevoked
+func (m *Manager[T]) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if m.store == nil {
+		return nil, ErrNoStore
+	}
+	claims, err := m.Parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != RefreshToken {
+		return nil, ErrInvalidToken
+	}
+	ok, err := m.store.Revoke(ctx, claims.ID, m.revokeUntil(claims))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTokenRevoked
+	}
+	return m.Issue(ctx, claims.Subject, claims.Data)
+}
+
+// Revoke 吊销token 例如退出登录时吊销访问token和刷新token
+func (m *Manager[T]) Revoke(ctx context.Context, token string) error {
+	if m.store == nil {
+		return ErrNoStore
+	}
+	claims, err := m.Parse(token)
+	if errors.Is(err, ErrTokenExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = m.store.Revoke(ctx, claims.ID, m.revokeUntil(claims))
+	return err
+}
+
+// revokeUntil 吊销记录需要保留的时间 token在过期后的时钟误差内仍然可以通过验证
+func (m *Manager[T]) revokeUntil(claims *Claims[T]) time.Time {
+	return claims.ExpiresTime().Add(m.leeway)
+}
+
+// Verify 验证token并检查是否已吊销 只接受访问token
+func (m *Manager[T]) Verify(ctx context.Context, token string) (*Claims[T], error) {
+	claims, err := m.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != AccessToken {
+		return nil, ErrInvalidToken
+	}
+	if m.store != nil && claims.ID != "" {
+		revoked, err := m.store.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// Sign 使用当前的签名密钥签名声明
+func (m *Manager[T]) Sign(claims *Claims[T]) (string, error) {
+	m.mu.RLock()
+	key := m.signingKey
+	m.mu.RUnlock()
+
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := encoding.EncodeToString(h) + "." + encoding.EncodeToString(payload)
+	signature, err := key.sign([]byte(unsigned))
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// Parse 验证token的签名和声明 不检查是否已吊销
+// 签名算法必须和kid对应密钥的算法一致 防止使用公钥作为HMAC密钥的攻击
+func (m *Manager[T]) Parse(token string) (*Claims[T], error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	h := &header{}
+	if err := decodeSegment(parts[0], h); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	m.mu.RLock()
+	key, ok := m.keys[h.KeyID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if h.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims[T]{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := m.validate(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *Manager[T]) validate(c *RegisteredClaims) error {
+	now := m.now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(m.leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(m.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrInvalidToken
+	}
+	if m.issuer != "" && c.Issuer != m.issuer {
+		return ErrInvalidToken
+	}
+	if m.audience != "" && !c.Audience.contains(m.audience) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (m *Manager[T]) newClaims(subject string, data T, typ string, now time.Time, ttl time.Duration) (*Claims[T], error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims[T]{
+		RegisteredClaims: RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   subject,
+			ExpiresAt: now.Add(ttl).Unix(),
+			NotBefore: now.Unix(),
+			IssuedAt:  now.Unix(),
+			ID:        id,
+		},
+		TokenType: typ,
+		Data:      data,
+	}
+	if m.audience != "" {
+		claims.Audience = Audience{m.audience}
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := encoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
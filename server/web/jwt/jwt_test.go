@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+	"github.com/redis/go-redis/v9"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func (s *memoryStore) Revoke(ctx context.Context, jti string, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.revoked[jti]; ok {
+		return false, nil
+	}
+	s.revoked[jti] = until
+	return true, nil
+}
+
+func (s *memoryStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func newManager(t *testing.T, opts ...Option) *Manager[user] {
+	t.Helper()
+	opts = append([]Option{WithIssuer("test"), WithStore(&memoryStore{revoked: map[string]time.Time{}})}, opts...)
+	m, err := New[user](NewHS256Key("k1", []byte("0123456789abcdef0123456789abcdef")), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	ctx := context.Background()
+	m := newManager(t)
+	pair, err := m.Issue(ctx, "42", user{Name: "tom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.Verify(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "42" || claims.Data.Name != "tom" || claims.Issuer != "test" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := m.Verify(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token as access token: %v", err)
+	}
+	tampered := pair.AccessToken[:len(pair.AccessToken)-2] + "xx"
+	if _, err := m.Verify(ctx, tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token: %v", err)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(3 * time.Hour) }
+	if _, err := m.Verify(ctx, pair.AccessToken); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired token: %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	m := newManager(t)
+	old, _ := m.Issue(ctx, "1", user{})
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rotate(NewRS256Key("k2", priv)); err != nil {
+		t.Fatal(err)
+	}
+	pair, _ := m.Issue(ctx, "2", user{})
+	if _, err := m.Verify(ctx, pair.AccessToken); err != nil {
+		t.Errorf("new key: %v", err)
+	}
+	if _, err := m.Verify(ctx, old.AccessToken); err != nil {
+		t.Errorf("old key: %v", err)
+	}
+
+	m.RemoveKey("k1")
+	if _, err := m.Verify(ctx, old.AccessToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("removed key: %v", err)
+	}
+
+	// 只有公钥的服务可以验证但不能签名
+	verifier, err := New[user](NewHS256Key("local", []byte("local secret 0123456789abcdefghij")), WithVerifyKeys(NewRS256PublicKey("k2", &priv.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(ctx, pair.AccessToken); err != nil {
+		t.Errorf("public key: %v", err)
+	}
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	m := newManager(t)
+	other, _ := New[user](NewHS256Key("k1", []byte("another secret 0123456789abcdefg")))
+	token, _ := other.Sign(&Claims[user]{RegisteredClaims: RegisteredClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()}, TokenType: AccessToken})
+	if _, err := m.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong secret: %v", err)
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	m := newManager(t)
+	pair, _ := m.Issue(ctx, "42", user{Name: "tom"})
+
+	next, err := m.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.Verify(ctx, next.AccessToken)
+	if err != nil || claims.Data.Name != "tom" {
+		t.Fatalf("refreshed claims = %+v, %v", claims, err)
+	}
+	if _, err := m.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("reused refresh token: %v", err)
+	}
+	if _, err := m.Refresh(ctx, next.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token as refresh token: %v", err)
+	}
+
+	if err := m.Revoke(ctx, next.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ctx, next.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked token: %v", err)
+	}
+}
+
+func TestRefreshWithinLeeway(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{revoked: map[string]time.Time{}}
+	m := newManager(t, WithStore(store), WithLeeway(time.Minute), WithRefreshTTL(time.Hour))
+	pair, _ := m.Issue(ctx, "42", user{Name: "tom"})
+
+	// 刷新token已过期但仍在时钟误差内
+	m.now = func() time.Time { return pair.RefreshExpiresAt.Add(30 * time.Second) }
+	if _, err := m.Refresh(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("reused refresh token: %v", err)
+	}
+	for _, until := range store.revoked {
+		if !until.Equal(pair.RefreshExpiresAt.Add(time.Minute)) {
+			t.Errorf("until = %s", until)
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	// 已经过去的时间也需要保存 拒绝重复使用
+	for i, want := range []bool{true, false} {
+		ok, err := store.Revoke(ctx, "jti", time.Now().Add(-time.Second))
+		if err != nil || ok != want {
+			t.Errorf("revoke %d = %v, %v", i, ok, err)
+		}
+	}
+	if revoked, _ := store.IsRevoked(ctx, "jti"); !revoked {
+		t.Error("jti was not stored")
+	}
+}
+
+func TestShortSecret(t *testing.T) {
+	if _, err := New[user](NewHS256Key("k1", []byte("secret"))); !errors.Is(err, ErrShortSecret) {
+		t.Errorf("New = %v", err)
+	}
+	m := newManager(t)
+	if err := m.Rotate(NewHS256Key("k2", []byte("short"))); !errors.Is(err, ErrShortSecret) {
+		t.Errorf("Rotate = %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newManager(t)
+	engine := gin.New()
+	engine.GET("/me", m.Middleware(), web.Handle(func(ctx context.Context, req *struct{}) (*user, error) {
+		claims, ok := FromContext[user](ctx)
+		if !ok {
+			return nil, web.UNAUTHORIZED
+		}
+		return &claims.Data, nil
+	}))
+
+	pair, _ := m.Issue(context.Background(), "42", user{Name: "tom"})
+	expired, _ := m.Sign(&Claims[user]{RegisteredClaims: RegisteredClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}, TokenType: AccessToken})
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		code   int
+	}{
+		{"missing", "", http.StatusUnauthorized, web.UNAUTHORIZED.Code},
+		{"invalid", "Bearer abc.def.ghi", http.StatusUnauthorized, web.InvalidToken.Code},
+		{"expired", "Bearer " + expired, http.StatusUnauthorized, web.TokenExpired.Code},
+		{"ok", "Bearer " + pair.AccessToken, http.StatusOK, web.SUCCESS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			res := &web.Response{}
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || res.Code != tt.code {
+				t.Errorf("status = %d, code = %d, body = %s", w.Code, res.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+
+	// minSecretLength HS256密钥的最小长度 和签名的长度相同
+	minSecretLength = 32
+)
+
+// Key 签名和验证token的密钥 使用 kid 区分 轮换密钥时旧的密钥继续用于验证
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewHS256Key 创建HS256密钥 secret 至少32字节
+func NewHS256Key(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Algorithm: HS256, secret: secret}
+}
+
+// NewRS256Key 创建RS256密钥 可以签名和验证
+func NewRS256Key(kid string, privateKey *rsa.PrivateKey) *Key {
+	return &Key{ID: kid, Algorithm: RS256, privateKey: privateKey, publicKey: &privateKey.PublicKey}
+}
+
+// NewRS256PublicKey 创建只能验证的RS256密钥 用于只校验token的服务
+func NewRS256PublicKey(kid string, publicKey *rsa.PublicKey) *Key {
+	return &Key{ID: kid, Algorithm: RS256, publicKey: publicKey}
+}
+
+// ParseRS256Key 从PEM格式的私钥创建RS256密钥 支持PKCS1和PKCS8
+func ParseRS256Key(kid string, pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("jwt: 无效的PEM私钥")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewRS256Key(kid, key), nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("jwt: 私钥不是RSA私钥")
+	}
+	return NewRS256Key(kid, rsaKey), nil
+}
+
+// ParseRS256PublicKey 从PEM格式的公钥创建只能验证的RS256密钥
+func ParseRS256PublicKey(kid string, pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("jwt: 无效的PEM公钥")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("jwt: 公钥不是RSA公钥")
+	}
+	return NewRS256PublicKey(kid, rsaKey), nil
+}
+
+// canSign 是否可以用于签名
+func (k *Key) canSign() bool {
+	switch k.Algorithm {
+	case HS256:
+		return len(k.secret) > 0
+	case RS256:
+		return k.privateKey != nil
+	}
+	return false
+}
+
+func (k *Key) sign(data []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case RS256:
+		if k.privateKey == nil {
+			return nil, errors.New("jwt: 密钥没有私钥 不能签名")
+		}
+		sum := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, k.privateKey, crypto.SHA256, sum[:])
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func (k *Key) verify(data, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.publicKey, crypto.SHA256, sum[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+// claimsKey gin上下文中保存声明的key
+const claimsKey = "jwt.claims"
+
+type claimsContextKey struct{}
+
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	lookups  []func(c *gin.Context) string
+	optional bool
+}
+
+// WithHeader 从请求头获取token 值可以带 Bearer 前缀 默认从 Authorization 获取
+func WithHeader(name string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.lookups = append(o.lookups, func(c *gin.Context) string {
+			token := c.GetHeader(name)
+			if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+				return strings.TrimSpace(token[7:])
+			}
+			return token
+		})
+	}
+}
+
+// WithQuery 从查询参数获取token 例如websocket等不能设置请求头的场景
+func WithQuery(name string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.lookups = append(o.lookups, func(c *gin.Context) string {
+			return c.Query(name)
+		})
+	}
+}
+
+// WithCookie 从cookie获取token
+func WithCookie(name string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.lookups = append(o.lookups, func(c *gin.Context) string {
+			token, _ := c.Cookie(name)
+			return token
+		})
+	}
+}
+
+// WithOptional 没有token时继续处理请求 有token时仍然要求token有效
+func WithOptional() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.optional = true
+	}
+}
+
+// Middleware 认证中间件 验证通过后把声明保存到gin上下文和请求的ctx中 并使用 web.SetUserID 保存 sub
+// 没有token时返回 web.UNAUTHORIZED token过期时返回 web.TokenExpired 其他错误返回 web.InvalidToken
+func (m *Manager[T]) Middleware(opts ...MiddlewareOption) gin.HandlerFunc {
+	o := &middlewareOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.lookups) == 0 {
+		WithHeader("Authorization")(o)
+	}
+	return func(c *gin.Context) {
+		token := ""
+		for _, lookup := range o.lookups {
+			if token = lookup(c); token != "" {
+				break
+			}
+		}
+		if token == "" {
+			if o.optional {
+				c.Next()
+				return
+			}
+			abort(c, ErrTokenMissing)
+			return
+		}
+		claims, err := m.Verify(c.Request.Context(), token)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), claimsContextKey{}, claims))
+		web.SetUserID(c, claims.Subject)
+		c.Next()
+	}
+}
+
+// abort 使用内置的认证错误返回
+func abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	web.NewGinActionImpl(c).ThrowError(ErrorModel(err))
+}
+
+// ErrorModel 把认证错误转换为内置的 web.UNAUTHORIZED web.InvalidToken web.TokenExpired
+func ErrorModel(err error) *web.ErrorModel {
+	switch {
+	case errors.Is(err, ErrTokenMissing):
+		return web.UNAUTHORIZED
+	case errors.Is(err, ErrTokenExpired):
+		return web.TokenExpired
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenRevoked), errors.Is(err, ErrUnknownKey):
+		return web.InvalidToken
+	}
+	// 吊销记录存储不可用等内部错误
+	return web.ServerError
+}
+
+// FromContext 获取中间件保存的声明 ctx 可以是 *gin.Context、Handle 传入的ctx或请求的ctx
+func FromContext[T any](ctx context.Context) (*Claims[T], bool) {
+	if c, ok := ctx.(*gin.Context); ok {
+		v, exists := c.Get(claimsKey)
+		if !exists {
+			return nil, false
+		}
+		claims, ok := v.(*Claims[T])
+		return claims, ok
+	}
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims[T])
+	return claims, ok
+}
@@ -0,0 +1,54 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRevokePrefix 吊销记录key的默认前缀
+const DefaultRevokePrefix = "jwt:revoked:"
+
+// RevocationStore 保存已吊销token的jti 记录只需要保留到 until
+type RevocationStore interface {
+	// Revoke 吊销jti 返回jti之前是否未被吊销 刷新token时用于保证同一个刷新token只能使用一次
+	// until 为token的过期时间加上 WithLeeway 设置的时钟误差 之后token不能再通过验证
+	Revoke(ctx context.Context, jti string, until time.Time) (bool, error)
+	// IsRevoked jti是否已吊销
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RedisStore 使用redis保存吊销记录
+type RedisStore struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		Client: client,
+		Prefix: DefaultRevokePrefix,
+	}
+}
+
+// Revoke 实现 RevocationStore 使用 SET NX 保证并发刷新时只有一个成功
+// until 已经过去时记录至少保存1秒 仍然可以拒绝并发的重复刷新
+func (r *RedisStore) Revoke(ctx context.Context, jti string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return r.Client.SetNX(ctx, r.Prefix+jti, 1, ttl).Result()
+}
+
+// IsRevoked 实现 RevocationStore
+func (r *RedisStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.Client.Exists(ctx, r.Prefix+jti).Result()
+	return n > 0, err
+}
+
+// HealthCheck ping redis 实现 health.HealthChecker
+func (r *RedisStore) HealthCheck(ctx context.Context) error {
+	return r.Client.Ping(ctx).Err()
+}
@@ -6,34 +6,65 @@
 package web
 
 import (
+	"context"
+	"io"
+
 	"github.com/lshaofan/cb-framework/core/logger"
 	"github.com/sirupsen/logrus"
 )
 
 type Logger struct {
 	logger *logrus.Logger
+	fields logrus.Fields
 }
 
+// NewLogger 创建http日志 args 为请求的 *gin.Context 或 context.Context 时日志自动带上请求id
 func NewLogger(args interface{}) *Logger {
-	l := logrus.New()
-	l.SetLevel(logrus.InfoLevel)
 	writer, err := logger.GetOutput("http", "exception")
 	if err != nil {
 		panic(err)
 	}
-	l.SetOutput(writer)
+	return (&Logger{logger: newJSONLogger(writer)}).withArgs(args)
+}
+
+// newJSONLogger 创建输出到w的JSON格式日志
+func newJSONLogger(w io.Writer) *logrus.Logger {
+	l := logrus.New()
+	l.SetLevel(logrus.InfoLevel)
+	l.SetOutput(w)
 	l.SetFormatter(&logrus.JSONFormatter{
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
-	return &Logger{logger: l}
+	return l
+}
+
+// WithContext 返回带有ctx中请求id的日志
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return l.withArgs(ctx)
+}
+
+func (l *Logger) withArgs(args interface{}) *Logger {
+	ctx, ok := args.(context.Context)
+	if !ok {
+		return l
+	}
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return l
+	}
+	fields := logrus.Fields{"request_id": id}
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	return &Logger{logger: l.logger, fields: fields}
 }
 
 // AddErrorLog 添加错误日志
 func (l *Logger) AddErrorLog(fields map[string]interface{}) {
-	l.logger.WithFields(fields).Error()
+	l.logger.WithFields(l.fields).WithFields(fields).Error()
 }
 
 // AddInfoLog 添加信息日志
 func (l *Logger) AddInfoLog(fields map[string]interface{}) {
-	l.logger.WithFields(fields).Info()
+	l.logger.WithFields(l.fields).WithFields(fields).Info()
 }
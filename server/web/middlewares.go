@@ -6,10 +6,22 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/gin-gonic/gin"
+	"io"
+	"net"
 	"net/http"
+	"os"
 	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/core/logger"
+	"github.com/sirupsen/logrus"
 )
 
 func PrintStack() {
@@ -18,41 +30,234 @@ func PrintStack() {
 	fmt.Printf("==> %s\n", string(buf[:n]))
 }
 
-// GinException 异常处理中间件
-func GinException() gin.HandlerFunc {
+// Panic 请求处理中发生的panic
+type Panic struct {
+	// ID 错误id 生产环境中返回给客户端 用于和日志对应
+	ID        string
+	Value     any
+	Stack     string
+	File      string
+	Line      int
+	Func      string
+	Method    string
+	Path      string
+	Route     string
+	IP        string
+	RequestID string
+	UserID    string
+	Time      time.Time
+}
+
+// Notifier panic通知 例如发送到告警群或错误收集服务
+// 通知在新的goroutine中调用 ctx 为请求的ctx 但不会随请求结束而取消
+type Notifier interface {
+	Notify(ctx context.Context, p *Panic)
+}
+
+// NotifierFunc 函数形式的 Notifier
+type NotifierFunc func(ctx context.Context, p *Panic)
+
+func (f NotifierFunc) Notify(ctx context.Context, p *Panic) {
+	f(ctx, p)
+}
+
+type RecoveryOption func(*recoveryOptions)
+
+type recoveryOptions struct {
+	output     io.Writer
+	production bool
+	stderr     *bool
+	notifiers  []Notifier
+}
+
+// WithRecoveryOutput 设置异常日志的输出 默认为 runtime/log/<日期>/http/exception.log
+func WithRecoveryOutput(w io.Writer) RecoveryOption {
+	return func(o *recoveryOptions) {
+		o.output = w
+	}
+}
+
+// WithProduction 设置是否为生产环境 生产环境只返回通用的错误信息和错误id 默认gin为release模式时为生产环境
+func WithProduction(production bool) RecoveryOption {
+	return func(o *recoveryOptions) {
+		o.production = production
+	}
+}
+
+// WithStackToStderr 设置是否同时把堆栈打印到标准错误 默认非生产环境时打印
+func WithStackToStderr(enable bool) RecoveryOption {
+	return func(o *recoveryOptions) {
+		o.stderr = &enable
+	}
+}
+
+// WithNotifier 添加panic通知 只有返回5xx的panic会通知
+func WithNotifier(notifiers ...Notifier) RecoveryOption {
+	return func(o *recoveryOptions) {
+		o.notifiers = append(o.notifiers, notifiers...)
+	}
+}
+
+// Recovery 异常处理中间件 把panic的完整堆栈和发生位置记录到结构化日志中
+// panic的值为 *ErrorModel 时按其状态码和信息返回 其他panic返回500
+// 生产环境中只返回通用的错误信息和错误id 不暴露内部细节
+func Recovery(opts ...RecoveryOption) gin.HandlerFunc {
+	o := &recoveryOptions{production: gin.Mode() == gin.ReleaseMode}
+	for _, opt := range opts {
+		opt(o)
+	}
+	stderr := !o.production
+	if o.stderr != nil {
+		stderr = *o.stderr
+	}
+	if o.output == nil {
+		writer, err := logger.GetOutput("http", "exception")
+		if err != nil {
+			panic(err)
+		}
+		o.output = writer
+	}
+	l := newJSONLogger(o.output)
+
 	return func(c *gin.Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				// 打印堆栈信息
-				PrintStack()
-				logger := NewLogger(map[string]interface{}{"name": "exception", "path": "http"})
-				logFields := make(map[string]interface{})
-				// 记录日志
-				logFields["ip"] = c.ClientIP()
-				logFields["error"] = err
-				logFields["请求地址"] = c.Request.URL
-				logFields["method"] = c.Request.Method
-				// 行号
-				logFields["line"], _, _, _ = runtime.Caller(1)
-				logger.AddErrorLog(logFields)
-				var message string
-				// 判断err类型
-				switch expr := err.(type) {
-				case string:
-					message = expr
-				case error:
-					message = expr.Error()
-				default:
-					message = fmt.Sprintf("%v", expr)
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			p := newPanic(c, v)
+			fields := logrus.Fields{
+				"error_id":   p.ID,
+				"error":      fmt.Sprintf("%v", v),
+				"file":       p.File,
+				"line":       p.Line,
+				"func":       p.Func,
+				"method":     p.Method,
+				"path":       p.Path,
+				"route":      p.Route,
+				"query":      c.Request.URL.RawQuery,
+				"ip":         p.IP,
+				"request_id": p.RequestID,
+				"user_id":    p.UserID,
+			}
+
+			// 客户端断开连接时无法再写入响应
+			if brokenPipe(v) {
+				l.WithFields(fields).Warn("connection closed by client")
+				c.Abort()
+				return
+			}
+
+			var model *ErrorModel
+			if err, ok := v.(error); ok && errors.As(err, &model) && model.HttpStatus < http.StatusInternalServerError {
+				l.WithFields(fields).Warn("panic")
+			} else {
+				fields["stack"] = p.Stack
+				l.WithFields(fields).Error("panic")
+				if stderr {
+					fmt.Fprintf(os.Stderr, "==> panic: %v\n%s\n", v, p.Stack)
 				}
-				c.AbortWithStatusJSON(http.StatusInternalServerError, NewResponse(
-					ERROR,
-					message,
-					nil,
-				))
+				o.notify(c, p)
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
 				return
 			}
+			c.AbortWithStatusJSON(o.response(c, p, model))
 		}()
 		c.Next()
 	}
 }
+
+// GinException 异常处理中间件 等同于使用默认配置的 Recovery
+func GinException() gin.HandlerFunc {
+	return Recovery()
+}
+
+// response panic对应的状态码和响应
+func (o *recoveryOptions) response(c *gin.Context, p *Panic, model *ErrorModel) (int, *Response) {
+	locale := GetLocale(c)
+	var res *Response
+	status := http.StatusInternalServerError
+	switch {
+	case model != nil:
+		status = model.HttpStatus
+		res = NewResponse(model.Code, TranslateError(locale, model), model.Result)
+	case o.production:
+		res = NewResponse(ERROR, TranslateError(locale, ServerError), gin.H{"error_id": p.ID})
+	default:
+		res = NewResponse(ERROR, fmt.Sprintf("%v", p.Value), gin.H{"error_id": p.ID})
+	}
+	res.RequestID = responseRequestID(c)
+	return status, res
+}
+
+func (o *recoveryOptions) notify(c *gin.Context, p *Panic) {
+	if len(o.notifiers) == 0 {
+		return
+	}
+	ctx := context.WithoutCancel(c.Request.Context())
+	for _, n := range o.notifiers {
+		go func(n Notifier) {
+			defer func() {
+				_ = recover()
+			}()
+			n.Notify(ctx, p)
+		}(n)
+	}
+}
+
+func newPanic(c *gin.Context, v any) *Panic {
+	p := &Panic{
+		ID:        newRequestID(),
+		Value:     v,
+		Stack:     string(debug.Stack()),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		IP:        c.ClientIP(),
+		RequestID: GetRequestID(c),
+		UserID:    GetUserID(c),
+		Time:      time.Now(),
+	}
+	if frame, ok := panicFrame(); ok {
+		p.File, p.Line, p.Func = frame.File, frame.Line, frame.Function
+	}
+	return p
+}
+
+// panicFrame 查找panic发生的位置 即 runtime.gopanic 之后第一个不在runtime包中的调用
+func panicFrame() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		} else if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// brokenPipe 判断panic是否因为客户端断开连接导致
+func brokenPipe(v any) bool {
+	err, ok := v.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	return errors.Is(opErr, syscall.EPIPE) || errors.Is(opErr, syscall.ECONNRESET)
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRecoveryEngine(buf *bytes.Buffer, opts ...RecoveryOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Recovery(append([]RecoveryOption{WithRecoveryOutput(buf), WithStackToStderr(false)}, opts...)...))
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("secret detail")
+	})
+	engine.GET("/forbidden", func(c *gin.Context) {
+		panic(Forbidden)
+	})
+	engine.GET("/broken", func(c *gin.Context) {
+		panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	})
+	engine.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	})
+	return engine
+}
+
+func recoveryLog(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	entry := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log = %q", buf.String())
+	}
+	buf.Reset()
+	return entry
+}
+
+func TestRecoveryResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		production bool
+		message    string
+	}{
+		{"development", false, "secret detail"},
+		{"production", true, ServerError.Message},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			engine := newRecoveryEngine(&buf, WithProduction(tt.production))
+			w, res := serve(engine, httptest.NewRequest(http.MethodGet, "/panic", nil))
+			if w.Code != http.StatusInternalServerError || res.Code != ERROR || res.Message != tt.message {
+				t.Fatalf("response = %d %s", w.Code, w.Body.String())
+			}
+
+			// 响应中的error_id和日志对应
+			result, _ := res.Result.(map[string]any)
+			entry := recoveryLog(t, &buf)
+			if result["error_id"] == "" || result["error_id"] != entry["error_id"] {
+				t.Errorf("error_id = %v; log = %v", result["error_id"], entry["error_id"])
+			}
+			if entry["level"] != "error" || entry["error"] != "secret detail" || entry["route"] != "/panic" || entry["stack"] == "" {
+				t.Errorf("log = %v", entry)
+			}
+			if file, _ := entry["file"].(string); !strings.HasSuffix(file, "middlewares_test.go") {
+				t.Errorf("file = %v", entry["file"])
+			}
+		})
+	}
+}
+
+func TestRecoveryErrorModel(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newRecoveryEngine(&buf, WithProduction(true))
+	w, res := serve(engine, httptest.NewRequest(http.MethodGet, "/forbidden", nil))
+	if w.Code != http.StatusForbidden || res.Code != Forbidden.Code || res.Message != Forbidden.Message {
+		t.Errorf("response = %d %s", w.Code, w.Body.String())
+	}
+	// 4xx的panic只记录warning 不记录堆栈
+	if entry := recoveryLog(t, &buf); entry["level"] != "warning" || entry["stack"] != nil {
+		t.Errorf("log = %v", entry)
+	}
+}
+
+func TestRecoveryBrokenPipe(t *testing.T) {
+	var buf bytes.Buffer
+	notified := make(chan struct{}, 1)
+	engine := newRecoveryEngine(&buf, WithNotifier(NotifierFunc(func(ctx context.Context, p *Panic) {
+		notified <- struct{}{}
+	})))
+	w, _ := serve(engine, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %s", w.Body.String())
+	}
+	if entry := recoveryLog(t, &buf); entry["level"] != "warning" || entry["msg"] != "connection closed by client" {
+		t.Errorf("log = %v", entry)
+	}
+	select {
+	case <-notified:
+		t.Error("broken pipe was notified")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRecoveryWritten(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newRecoveryEngine(&buf)
+	w, _ := serve(engine, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("response = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRecoveryNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	got := make(chan *Panic, 2)
+	engine := gin.New()
+	engine.Use(RequestID(), func(c *gin.Context) {
+		SetUserID(c, "42")
+	}, Recovery(WithRecoveryOutput(&buf), WithStackToStderr(false), WithNotifier(
+		NotifierFunc(func(ctx context.Context, p *Panic) {
+			if RequestIDFromContext(ctx) != p.RequestID {
+				t.Error("notifier ctx has no request id")
+			}
+			got <- p
+		}),
+		// 通知中的panic不影响其他通知
+		NotifierFunc(func(ctx context.Context, p *Panic) {
+			panic("notifier failed")
+		}),
+	)))
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	engine.GET("/forbidden", func(c *gin.Context) {
+		panic(Forbidden)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	serve(engine, req)
+	select {
+	case p := <-got:
+		if p.Value != "boom" || p.RequestID != "req-1" || p.UserID != "42" || p.Route != "/panic" || p.ID == "" || p.Stack == "" {
+			t.Errorf("panic = %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notifier was not called")
+	}
+
+	// 4xx的panic不通知
+	serve(engine, httptest.NewRequest(http.MethodGet, "/forbidden", nil))
+	select {
+	case p := <-got:
+		t.Errorf("notified %v", p.Value)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestGinException(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	// release模式下默认为生产环境 不打印堆栈到标准错误
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(GinException())
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	w, res := serve(engine, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError || res.Message != ServerError.Message {
+		t.Errorf("response = %d %s", w.Code, w.Body.String())
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "runtime", "log", "*", "http", "exception.log.*"))
+	if len(matches) != 1 {
+		t.Fatalf("log files = %v", matches)
+	}
+	if data, _ := os.ReadFile(matches[0]); !bytes.Contains(data, []byte(`"error":"boom"`)) {
+		t.Errorf("log = %s", data)
+	}
+}
@@ -0,0 +1,504 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+// Version 生成的OpenAPI版本
+const Version = "3.0.3"
+
+// defaultErrors 框架预定义的错误 文档中列出其错误码
+var defaultErrors = []*web.ErrorModel{
+	web.ServerError,
+	web.UNAUTHORIZED,
+	web.InvalidToken,
+	web.TokenExpired,
+	web.UsernameOrPasswordError,
+	web.PlatformNotExist,
+	web.PlatformIdCanNotEmpty,
+	web.Forbidden,
+	web.TooManyRequests,
+	web.IdempotencyKeyInProgress,
+	web.IdempotencyKeyMismatch,
+	web.IdempotencyKeyRequired,
+	web.IdempotencyKeyInvalid,
+	web.RequestEntityTooLarge,
+}
+
+type Option func(*Generator)
+
+// WithInfo 设置文档的标题和版本
+func WithInfo(title, version string) Option {
+	return func(g *Generator) {
+		g.info.Title, g.info.Version = title, version
+	}
+}
+
+// WithDescription 设置文档的说明
+func WithDescription(description string) Option {
+	return func(g *Generator) {
+		g.info.Description = description
+	}
+}
+
+// WithServer 添加服务地址
+func WithServer(url, description string) Option {
+	return func(g *Generator) {
+		g.servers = append(g.servers, Server{URL: url, Description: description})
+	}
+}
+
+// WithErrors 添加业务定义的错误 文档中列出其错误码
+func WithErrors(errs ...*web.ErrorModel) Option {
+	return func(g *Generator) {
+		g.errors = append(g.errors, errs...)
+	}
+}
+
+// WithExclude 不生成以prefix开头的路由
+func WithExclude(prefixes ...string) Option {
+	return func(g *Generator) {
+		g.exclude = append(g.exclude, prefixes...)
+	}
+}
+
+type RouteOption func(*Operation)
+
+// WithSummary 设置接口的标题
+func WithSummary(summary string) RouteOption {
+	return func(o *Operation) {
+		o.Summary = summary
+	}
+}
+
+// WithRouteDescription 设置接口的说明
+func WithRouteDescription(description string) RouteOption {
+	return func(o *Operation) {
+		o.Description = description
+	}
+}
+
+// WithTags 设置接口的分组 默认为路径中的第一段
+func WithTags(tags ...string) RouteOption {
+	return func(o *Operation) {
+		o.Tags = tags
+	}
+}
+
+// WithDeprecated 标记接口已废弃
+func WithDeprecated() RouteOption {
+	return func(o *Operation) {
+		o.Deprecated = true
+	}
+}
+
+type route struct {
+	method string
+	path   string
+	types  web.RouteTypes
+	opts   []RouteOption
+}
+
+// Generator 根据gin的路由生成OpenAPI 3文档
+// 使用 web.Handle 的路由直接获取请求和响应类型 其他路由需要使用 Route 设置 没有设置时文档中没有请求参数和响应数据
+type Generator struct {
+	engine  *gin.Engine
+	info    Info
+	servers []Server
+	errors  []*web.ErrorModel
+	exclude []string
+
+	mu     sync.RWMutex
+	routes map[string]*route
+}
+
+func New(engine *gin.Engine, opts ...Option) *Generator {
+	g := &Generator{
+		engine: engine,
+		info:   Info{Title: "API", Version: "1.0.0"},
+		errors: append([]*web.ErrorModel{}, defaultErrors...),
+		routes: make(map[string]*route),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Route 手动设置路由的请求和响应类型以及说明 req 和 resp 为对应类型的值 例如 &CreateUserRequest{} 不需要时传nil
+func (g *Generator) Route(method, path string, req, resp any, opts ...RouteOption) *Generator {
+	r := &route{method: strings.ToUpper(method), path: path, opts: opts}
+	if req != nil {
+		r.types.Request = reflect.TypeOf(req)
+	}
+	if resp != nil {
+		r.types.Response = reflect.TypeOf(resp)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.routes[r.method+" "+r.path] = r
+	return g
+}
+
+// Register 在prefix下注册文档接口 prefix/openapi.json 为文档 prefix 为文档页面
+func (g *Generator) Register(router gin.IRouter, prefix string) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	base := ""
+	if group, ok := router.(interface{ BasePath() string }); ok {
+		base = strings.TrimSuffix(group.BasePath(), "/")
+	}
+	g.mu.Lock()
+	g.exclude = append(g.exclude, base+prefix)
+	g.mu.Unlock()
+	router.GET(prefix+"/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, g.Document())
+	})
+	router.GET(prefix, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fmt.Sprintf(docsPage, html.EscapeString(g.info.Title), strings.TrimSuffix(c.Request.URL.Path, "/")+"/openapi.json")))
+	})
+}
+
+// Document 生成文档 每次调用都重新生成 包含之后注册的路由
+func (g *Generator) Document() *Document {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.info,
+		Servers: g.servers,
+		Paths:   make(map[string]*PathItem),
+	}
+	s.components["Response"] = envelope(&Schema{Description: "业务数据"})
+	doc.Components.Responses = g.errorResponses()
+
+	tags := make(map[string]struct{})
+	for _, info := range g.engine.Routes() {
+		if g.excluded(info.Path) {
+			continue
+		}
+		r, ok := g.routes[info.Method+" "+info.Path]
+		if !ok {
+			r = &route{method: info.Method, path: info.Path}
+			r.types, _ = web.DescribeRoute(info)
+		}
+		op := g.operation(s, r, info.Handler)
+		for _, tag := range op.Tags {
+			tags[tag] = struct{}{}
+		}
+		path := toOpenAPIPath(info.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = &PathItem{}
+		}
+		setOperation(doc.Paths[path], info.Method, op)
+	}
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components.Schemas = s.components
+	return doc
+}
+
+func (g *Generator) excluded(path string) bool {
+	for _, prefix := range g.exclude {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// operation 生成接口
+func (g *Generator) operation(s *schemas, r *route, handler string) *Operation {
+	op := &Operation{
+		OperationID: operationID(r.method, r.path),
+		Summary:     handlerSummary(handler),
+		Responses:   make(map[string]*Response),
+	}
+	if tag := pathTag(r.path); tag != "" {
+		op.Tags = []string{tag}
+	}
+
+	if r.types.Request != nil {
+		g.request(s, op, r)
+		op.Responses["412"] = &Response{Ref: "#/components/responses/ValidationError"}
+	}
+	var result *Schema
+	if r.types.Response != nil {
+		result = s.schemaOf(r.types.Response)
+	}
+	op.Responses["200"] = &Response{
+		Description: "成功",
+		Content: map[string]*MediaType{
+			"application/json": {Schema: envelope(result)},
+		},
+	}
+	op.Responses["default"] = &Response{Ref: "#/components/responses/Error"}
+	for _, opt := range r.opts {
+		opt(op)
+	}
+	return op
+}
+
+// request 生成请求参数 uri标签为路径参数 header标签为请求头 有请求体的方法使用json字段作为请求体 其他方法使用form字段作为查询参数
+func (g *Generator) request(s *schemas, op *Operation, r *route) {
+	t := r.types.Request
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	hasBody := r.method == http.MethodPost || r.method == http.MethodPut || r.method == http.MethodPatch
+
+	body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	walkFields(t, func(f reflect.StructField) {
+		switch {
+		case tagName(f, "uri") != "":
+			op.Parameters = append(op.Parameters, parameter(s, f, tagName(f, "uri"), "path", true))
+		case tagName(f, "header") != "":
+			op.Parameters = append(op.Parameters, parameter(s, f, tagName(f, "header"), "header", false))
+		case hasBody:
+			if name := jsonName(f); name != "" && (tagName(f, "json") != "" || tagName(f, "form") == "") {
+				addProperty(s, body, name, f)
+			}
+			if name := formName(f); name != "" {
+				addProperty(s, form, name, f)
+			}
+		default:
+			if name := formName(f); name != "" {
+				op.Parameters = append(op.Parameters, parameter(s, f, name, "query", false))
+			}
+		}
+	})
+
+	if !hasBody {
+		return
+	}
+	content := make(map[string]*MediaType)
+	if len(body.Properties) > 0 {
+		content["application/json"] = &MediaType{Schema: body}
+	}
+	if len(form.Properties) > 0 && hasTag(t, "form") {
+		content["application/x-www-form-urlencoded"] = &MediaType{Schema: form}
+		content["multipart/form-data"] = &MediaType{Schema: form}
+	}
+	if len(content) > 0 {
+		op.RequestBody = &RequestBody{Required: true, Content: content}
+	}
+}
+
+// walkFields 遍历导出的字段 展开嵌入的结构体
+func walkFields(t reflect.Type, fn func(f reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("swaggerignore") == "true" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && tagName(f, "json") == "" && tagName(f, "form") == "" {
+			walkFields(ft, fn)
+			continue
+		}
+		if f.IsExported() {
+			fn(f)
+		}
+	}
+}
+
+func hasTag(t reflect.Type, key string) bool {
+	found := false
+	walkFields(t, func(f reflect.StructField) {
+		if _, ok := f.Tag.Lookup(key); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// formName 按gin的表单绑定规则获取字段名 没有form标签时使用字段名
+func formName(f reflect.StructField) string {
+	name := tagName(f, "form")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func addProperty(s *schemas, schema *Schema, name string, f reflect.StructField) {
+	field := s.schemaOf(f.Type)
+	if applyBinding(field, f.Tag.Get("binding")) {
+		schema.Required = append(schema.Required, name)
+	}
+	if desc := description(f); desc != "" {
+		field = withDescription(field, desc)
+	}
+	schema.Properties[name] = field
+}
+
+func parameter(s *schemas, f reflect.StructField, name, in string, required bool) *Parameter {
+	schema := s.schemaOf(f.Type)
+	if applyBinding(schema, f.Tag.Get("binding")) {
+		required = true
+	}
+	return &Parameter{
+		Name:        name,
+		In:          in,
+		Description: description(f),
+		Required:    required,
+		Schema:      schema,
+	}
+}
+
+// envelope web.Response 统一返回结构 result 为业务数据
+func envelope(result *Schema) *Schema {
+	if result == nil {
+		result = &Schema{Nullable: true}
+	}
+	return &Schema{
+		Type:     "object",
+		Required: []string{"code", "message", "result"},
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Description: "业务状态码 0为成功", Example: web.SUCCESS},
+			"message": {Type: "string", Description: "提示信息"},
+			"result":  result,
+		},
+	}
+}
+
+// errorResponses 校验错误和错误码的说明
+func (g *Generator) errorResponses() map[string]*Response {
+	validation := &Response{
+		Description: "参数校验失败 message为第一个错误 result可能为 字段->错误信息 的map",
+		Content: map[string]*MediaType{
+			"application/json": {Schema: envelope(&Schema{
+				Type:                 "object",
+				Nullable:             true,
+				AdditionalProperties: &Schema{Type: "array", Items: &Schema{Type: "string"}},
+			})},
+		},
+	}
+
+	var lines []string
+	examples := make(map[string]*Example)
+	for _, err := range g.errors {
+		lines = append(lines, fmt.Sprintf("- HTTP %d code %d: %s", err.HttpStatus, err.Code, err.Message))
+		examples[fmt.Sprintf("%d", err.Code)] = &Example{
+			Summary: fmt.Sprintf("HTTP %d %s", err.HttpStatus, err.Message),
+			Value:   web.NewResponse(err.Code, err.Message, err.Result),
+		}
+	}
+	return map[string]*Response{
+		"ValidationError": validation,
+		"Error": {
+			Description: "错误 code为业务错误码:\n" + strings.Join(lines, "\n"),
+			Content: map[string]*MediaType{
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/Response"}, Examples: examples},
+			},
+		},
+	}
+}
+
+var pathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// toOpenAPIPath 把gin的路径参数 :id *path 转换为 {id} {path}
+func toOpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// operationID 由方法和路径生成 例如 GET /users/:id 为 getUsersId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range nonIdent.Split(path, -1) {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// pathTag 路径中第一个不是api和版本号的静态段
+func pathTag(path string) string {
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "api" || versionSegment.MatchString(seg) || strings.ContainsAny(seg[:1], ":*") {
+			continue
+		}
+		return seg
+	}
+	return ""
+}
+
+// handlerSummary 使用处理函数名作为默认标题 例如 controller.(*User).Create-fm 为 User.Create
+func handlerSummary(handler string) string {
+	if handler == "" || strings.Contains(handler, ".func") {
+		return ""
+	}
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+}
+
+func setOperation(item *PathItem, method string, op *Operation) {
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodOptions:
+		item.Options = op
+	case http.MethodHead:
+		item.Head = op
+	case http.MethodPatch:
+		item.Patch = op
+	}
+}
+
+// docsPage 使用 swagger-ui 展示文档
+const docsPage = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function () {
+	SwaggerUIBundle({url: %q, dom_id: "#swagger-ui"});
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type createUser struct {
+	Platform string   `header:"X-Platform" binding:"required"`
+	Name     string   `json:"name" binding:"required,min=2,max=20" msg:"用户名"`
+	Email    string   `json:"email" binding:"omitempty,email"`
+	Role     string   `json:"role" binding:"oneof=admin user"`
+	Tags     []string `json:"tags" binding:"max=5,dive,min=1"`
+}
+
+type getUser struct {
+	ID int `uri:"id" binding:"required,gt=0"`
+}
+
+type listUsers struct {
+	web.ListRequest
+	Keyword string `form:"keyword"`
+}
+
+func newTestGenerator() (*gin.Engine, *Generator) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/api/v1/users", web.Handle(func(ctx context.Context, req *createUser) (*user, error) {
+		return &user{}, nil
+	}))
+	engine.GET("/api/v1/users/:id", web.Handle(func(ctx context.Context, req *getUser) (*user, error) {
+		return &user{ID: req.ID}, nil
+	}))
+	engine.GET("/api/v1/users", func(c *gin.Context) {
+		c.JSON(http.StatusOK, nil)
+	})
+	g := New(engine, WithInfo("测试", "1.0.0"))
+	g.Route(http.MethodGet, "/api/v1/users", &listUsers{}, &web.PageList[user]{}, WithSummary("用户列表"))
+	g.Register(engine, "/docs")
+	return engine, g
+}
+
+func TestDocument(t *testing.T) {
+	_, g := newTestGenerator()
+	doc := g.Document()
+
+	if _, ok := doc.Paths["/docs/openapi.json"]; ok {
+		t.Fatal("docs routes should be excluded")
+	}
+
+	create := doc.Paths["/api/v1/users"].Post
+	if create == nil {
+		t.Fatal("missing POST /api/v1/users")
+	}
+	if create.Tags[0] != "users" {
+		t.Errorf("tag = %v", create.Tags)
+	}
+	if len(create.Parameters) != 1 || create.Parameters[0].In != "header" || !create.Parameters[0].Required {
+		t.Errorf("header parameter = %+v", create.Parameters)
+	}
+	body := create.RequestBody.Content["application/json"].Schema
+	if len(body.Required) != 1 || body.Required[0] != "name" {
+		t.Errorf("required = %v", body.Required)
+	}
+	name := body.Properties["name"]
+	if *name.MinLength != 2 || *name.MaxLength != 20 || name.Description != "用户名" {
+		t.Errorf("name = %+v", name)
+	}
+	if body.Properties["email"].Format != "email" {
+		t.Errorf("email = %+v", body.Properties["email"])
+	}
+	if len(body.Properties["role"].Enum) != 2 {
+		t.Errorf("role = %+v", body.Properties["role"])
+	}
+	tags := body.Properties["tags"]
+	if *tags.MaxItems != 5 || *tags.Items.MinLength != 1 {
+		t.Errorf("tags = %+v", tags)
+	}
+	result := create.Responses["200"].Content["application/json"].Schema.Properties["result"]
+	if result.Ref != "#/components/schemas/user" {
+		t.Errorf("result = %+v", result)
+	}
+	if create.Responses["412"] == nil || create.Responses["default"] == nil {
+		t.Errorf("responses = %v", create.Responses)
+	}
+
+	get := doc.Paths["/api/v1/users/{id}"].Get
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].In != "path" || !get.Parameters[0].Schema.ExclusiveMinimum {
+		t.Errorf("get = %+v", get)
+	}
+
+	list := doc.Paths["/api/v1/users"].Get
+	if list.Summary != "用户列表" || len(list.Parameters) != 5 {
+		t.Errorf("list = %+v", list)
+	}
+	page := list.Responses["200"].Content["application/json"].Schema.Properties["result"]
+	if page.Ref != "#/components/schemas/PageList_openapi_user" {
+		t.Errorf("page = %+v", page)
+	}
+	if _, ok := doc.Components.Schemas["PageList_openapi_user"]; !ok {
+		t.Errorf("schemas = %v", doc.Components.Schemas)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	engine, _ := newTestGenerator()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != Version || doc.Info.Title != "测试" {
+		t.Errorf("doc = %+v", doc)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
+
+func TestUndescribedRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := func(c *gin.Context) {
+		action := web.NewGinActionImpl(c)
+		req := &getUser{}
+		if err := action.BindUriParam(req); err != nil {
+			action.ThrowValidateError(err)
+			return
+		}
+		action.Success(&user{ID: req.ID})
+	}
+	engine.GET("/orders/:id", handler)
+	engine.GET("/users/:id", handler)
+	g := New(engine)
+	g.Route(http.MethodGet, "/users/:id", &getUser{}, &user{})
+
+	// 文档不依赖处理过的请求
+	before, _ := json.Marshal(g.Document())
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	doc := g.Document()
+	if after, _ := json.Marshal(doc); string(after) != string(before) {
+		t.Errorf("document changed after requests:\n%s\n%s", before, after)
+	}
+
+	op := doc.Paths["/orders/{id}"].Get
+	if len(op.Parameters) != 0 || op.Responses["200"].Content["application/json"].Schema.Properties["result"].Ref != "" {
+		t.Errorf("undescribed = %+v", op)
+	}
+	op = doc.Paths["/users/{id}"].Get
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+	if result := op.Responses["200"].Content["application/json"].Schema.Properties["result"]; result.Ref != "#/components/schemas/user" {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -0,0 +1,302 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	bytesType      = reflect.TypeOf([]byte{})
+)
+
+// schemas 根据Go类型生成Schema 命名的结构体生成到 components.schemas 中并使用引用
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf 生成类型的Schema
+func (s *schemas) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "纳秒"}
+	case rawMessageType:
+		return &Schema{}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t, jsonName)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = s.componentName(t)
+			s.names[t] = name
+			// 先占位 防止递归的结构体无限展开
+			s.components[name] = &Schema{}
+			*s.components[name] = *s.structSchema(t, jsonName)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// fieldNamer 获取字段在请求或响应中的名称 返回空时跳过字段
+type fieldNamer func(f reflect.StructField) string
+
+// jsonName 按 encoding/json 的规则获取字段名
+func jsonName(f reflect.StructField) string {
+	name := tagName(f, "json")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// structSchema 生成结构体的Schema 没有标签的嵌入结构体展开到当前结构体
+func (s *schemas) structSchema(t reflect.Type, namer fieldNamer) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t, namer)
+	return schema
+}
+
+func (s *schemas) addFields(schema *Schema, t reflect.Type, namer fieldNamer) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("swaggerignore") == "true" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && tagName(f, "json") == "" {
+			s.addFields(schema, ft, namer)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := namer(f)
+		if name == "" {
+			continue
+		}
+		field := s.schemaOf(f.Type)
+		required := applyBinding(field, f.Tag.Get("binding"))
+		if desc := description(f); desc != "" {
+			field = withDescription(field, desc)
+		}
+		schema.Properties[name] = field
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// withDescription 引用的Schema不能有其他字段 使用allOf包装
+func withDescription(schema *Schema, desc string) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Description: desc}
+	}
+	schema.Description = desc
+	return schema
+}
+
+// description 字段的说明 使用 description 或 msg 标签
+func description(f reflect.StructField) string {
+	if desc := f.Tag.Get("description"); desc != "" {
+		return desc
+	}
+	return f.Tag.Get("msg")
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// componentName 生成组件名 泛型参数只保留包名和类型名 例如 PageList[a/b/user.User] 为 PageList_user_User
+func (s *schemas) componentName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.Index(name, "["); i >= 0 {
+		args := strings.Split(strings.TrimSuffix(name[i+1:], "]"), ",")
+		for j, arg := range args {
+			args[j] = arg[strings.LastIndex(arg, "/")+1:]
+		}
+		name = name[:i] + "_" + strings.Join(args, "_")
+	}
+	name = strings.Trim(nonIdent.ReplaceAllString(name, "_"), "_")
+	if _, taken := s.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	pkg = nonIdent.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:], "_")
+	base := pkg + "_" + name
+	name = base
+	for i := 2; ; i++ {
+		if _, taken := s.components[name]; !taken {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+// applyBinding 把binding标签中的规则转换为Schema的约束 返回字段是否必填
+// dive 之后的规则作用于数组元素
+func applyBinding(schema *Schema, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	required := false
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			if target.Items == nil {
+				break
+			}
+			target = target.Items
+			continue
+		}
+		if name == "required" && target == schema {
+			required = true
+			continue
+		}
+		if target.Ref != "" {
+			continue
+		}
+		applyRule(target, name, param)
+	}
+	return required
+}
+
+func applyRule(schema *Schema, name, param string) {
+	isNumber := schema.Type == "integer" || schema.Type == "number"
+	isArray := schema.Type == "array" || schema.Type == "object"
+	switch name {
+	case "min", "gte", "max", "lte", "len", "gt", "lt":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		lower := name == "min" || name == "gte" || name == "gt" || name == "len"
+		upper := name == "max" || name == "lte" || name == "lt" || name == "len"
+		switch {
+		case isNumber:
+			if lower {
+				schema.Minimum = &n
+				schema.ExclusiveMinimum = name == "gt"
+			}
+			if upper {
+				schema.Maximum = &n
+				schema.ExclusiveMaximum = name == "lt"
+			}
+		case isArray:
+			setLength(&schema.MinItems, &schema.MaxItems, name, n, lower, upper)
+		case schema.Type == "string":
+			setLength(&schema.MinLength, &schema.MaxLength, name, n, lower, upper)
+		}
+	case "oneof":
+		for _, v := range strings.Fields(param) {
+			schema.Enum = append(schema.Enum, enumValue(v, schema.Type))
+		}
+	case "email":
+		schema.Format = "email"
+	case "url", "uri", "http_url":
+		schema.Format = "uri"
+	case "uuid", "uuid4", "uuid3", "uuid5":
+		schema.Format = "uuid"
+	case "ip", "ipv4":
+		schema.Format = "ipv4"
+	case "ipv6":
+		schema.Format = "ipv6"
+	case "datetime":
+		schema.Description = strings.TrimSpace(schema.Description + " 格式: " + param)
+	case "numeric", "number":
+		schema.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+	case "alpha":
+		schema.Pattern = `^[a-zA-Z]+$`
+	case "alphanum":
+		schema.Pattern = `^[a-zA-Z0-9]+$`
+	}
+}
+
+// setLength gt和lt对长度为开区间 转换为闭区间
+func setLength(min, max **int, name string, n float64, lower, upper bool) {
+	v := int(n)
+	if lower {
+		if name == "gt" {
+			v++
+		}
+		l := v
+		*min = &l
+	}
+	if upper {
+		u := int(n)
+		if name == "lt" {
+			u--
+		}
+		*max = &u
+	}
+}
+
+func enumValue(v, typ string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// tagName 获取标签中的名称部分
+func tagName(f reflect.StructField, key string) string {
+	tag, ok := f.Tag.Lookup(key)
+	if !ok {
+		return ""
+	}
+	return strings.Split(tag, ",")[0]
+}
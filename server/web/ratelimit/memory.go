@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery 每处理多少次请求清理一次过期的状态
+const sweepEvery = 1024
+
+// MemoryStore 单实例使用的内存存储
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	windows map[string]*window
+	ops     int
+}
+
+type bucket struct {
+	tokens  float64
+	last    int64
+	expires int64
+}
+
+type window struct {
+	index   int64
+	prev    float64
+	cur     float64
+	expires int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		windows: make(map[string]*window),
+	}
+}
+
+// TakeTokens 实现 Store
+func (s *MemoryStore) TakeTokens(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error) {
+	ms := now.UnixMilli()
+	capacity := float64(rate.capacity())
+	perMs := rate.perMillisecond()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(ms)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: ms}
+		s.buckets[key] = b
+	}
+	if ms > b.last {
+		b.tokens = math.Min(capacity, b.tokens+float64(ms-b.last)*perMs)
+		b.last = ms
+	}
+	allowed := b.tokens >= float64(n)
+	if allowed {
+		b.tokens -= float64(n)
+	}
+	b.expires = ms + int64(math.Ceil((capacity-b.tokens)/perMs)) + 1000
+	return bucketResult(rate, allowed, b.tokens, n), nil
+}
+
+// SlidingWindow 实现 Store
+func (s *MemoryStore) SlidingWindow(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error) {
+	ms := now.UnixMilli()
+	size := rate.Period.Milliseconds()
+	index, elapsed := ms/size, ms%size
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(ms)
+	w, ok := s.windows[key]
+	if !ok {
+		w = &window{index: index}
+		s.windows[key] = w
+	}
+	switch {
+	case w.index == index-1:
+		w.prev, w.cur = w.cur, 0
+	case w.index != index:
+		w.prev, w.cur = 0, 0
+	}
+	w.index = index
+	w.expires = (index + 2) * size
+
+	count := w.prev*float64(size-elapsed)/float64(size) + w.cur
+	allowed := count+float64(n) <= float64(rate.Limit)
+	if allowed {
+		w.cur += float64(n)
+	}
+	return windowResult(rate, allowed, w.prev, w.cur, elapsed, n), nil
+}
+
+// sweep 定期删除过期的状态 防止key过多时占用内存
+func (s *MemoryStore) sweep(now int64) {
+	s.ops++
+	if s.ops%sweepEvery != 0 {
+		return
+	}
+	for k, b := range s.buckets {
+		if b.expires < now {
+			delete(s.buckets, k)
+		}
+	}
+	for k, w := range s.windows {
+		if w.expires < now {
+			delete(s.windows, k)
+		}
+	}
+}
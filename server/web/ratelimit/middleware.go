@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+// KeyFunc 从请求中获取限流的key 返回空时不限流
+type KeyFunc func(c *gin.Context) string
+
+// ByIP 按客户端ip限流
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser 按 web.SetUserID 保存的用户id限流 未登录时按客户端ip限流
+func ByUser(c *gin.Context) string {
+	if id := web.GetUserID(c); id != "" {
+		return "user:" + id
+	}
+	return ByIP(c)
+}
+
+// ByRoute 按路由限流 所有客户端共享配额 用于保护下游服务
+func ByRoute(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return "route:" + c.Request.Method + ":" + route
+}
+
+// Compose 组合多个key 例如 Compose(ByRoute, ByIP) 为每个客户端在每个路由上单独计数 任一key为空时不限流
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		parts := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			key := fn(c)
+			if key == "" {
+				return ""
+			}
+			parts = append(parts, key)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	key      KeyFunc
+	failOpen bool
+	headers  bool
+}
+
+// WithKeyFunc 设置获取限流key的函数 默认为 ByIP
+func WithKeyFunc(fn KeyFunc) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.key = fn
+	}
+}
+
+// WithFailClosed 存储出错时拒绝请求 默认存储出错时放行 避免redis故障导致服务不可用
+func WithFailClosed() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.failOpen = false
+	}
+}
+
+// WithoutHeaders 不返回 RateLimit-* 响应头
+func WithoutHeaders() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.headers = false
+	}
+}
+
+// Middleware 限流中间件 返回 RateLimit-Limit RateLimit-Remaining RateLimit-Reset 和 RateLimit-Policy 响应头
+// 超过限制时返回 web.TooManyRequests 和 Retry-After 响应头
+func (l *Limiter) Middleware(opts ...MiddlewareOption) gin.HandlerFunc {
+	o := &middlewareOptions{key: ByIP, failOpen: true, headers: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	policy := strconv.Itoa(l.rate.Limit) + ";w=" + strconv.Itoa(seconds(l.rate.Period))
+	if l.rate.Burst > 0 {
+		policy += ";burst=" + strconv.Itoa(l.rate.Burst)
+	}
+	return func(c *gin.Context) {
+		key := o.key(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := l.Allow(c.Request.Context(), key)
+		if err != nil {
+			_ = c.Error(err)
+			if o.failOpen {
+				c.Next()
+				return
+			}
+			web.NewGinActionImpl(c).ThrowError(web.ServerError)
+			return
+		}
+		if o.headers {
+			c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+			c.Header("RateLimit-Policy", policy)
+		}
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(seconds(res.RetryAfter), 1)))
+			web.NewGinActionImpl(c).ThrowError(web.TooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
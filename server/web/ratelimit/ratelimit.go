@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Rate 限流速率 Period 内最多 Limit 次请求
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	// Burst 令牌桶的容量 即允许的突发请求数 为0时等于 Limit 滑动窗口不使用
+	Burst int
+}
+
+// PerSecond 每秒n次
+func PerSecond(n int) Rate {
+	return Rate{Limit: n, Period: time.Second}
+}
+
+// PerMinute 每分钟n次
+func PerMinute(n int) Rate {
+	return Rate{Limit: n, Period: time.Minute}
+}
+
+// PerHour 每小时n次
+func PerHour(n int) Rate {
+	return Rate{Limit: n, Period: time.Hour}
+}
+
+func (r Rate) capacity() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// perMillisecond 令牌每毫秒的生成速度
+func (r Rate) perMillisecond() float64 {
+	return float64(r.Limit) / float64(r.Period.Milliseconds())
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed bool
+	// Limit 最大请求数 令牌桶为桶的容量
+	Limit int
+	// Remaining 剩余的请求数
+	Remaining int
+	// Reset 配额完全恢复的时间 滑动窗口为当前窗口结束的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时需要等待的时间
+	RetryAfter time.Duration
+}
+
+// Store 限流状态的存储 now 为调用方的时间 多实例部署时需要保证服务器时间同步
+type Store interface {
+	// TakeTokens 令牌桶 从桶中取出n个令牌
+	TakeTokens(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error)
+	// SlidingWindow 滑动窗口 按上一个窗口计数的剩余比例加上当前窗口的计数估算最近一个周期的请求数
+	SlidingWindow(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error)
+}
+
+type Option func(*Limiter)
+
+// WithName 设置限流器名称 作为key的前缀 默认由算法和速率生成 速率相同的限流器需要分别计数时使用
+func WithName(name string) Option {
+	return func(l *Limiter) {
+		l.name = name
+	}
+}
+
+// Limiter 限流器
+type Limiter struct {
+	store     Store
+	rate      Rate
+	name      string
+	algorithm func(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error)
+}
+
+// NewTokenBucket 创建令牌桶限流器 令牌按 Rate 的速度生成 桶满时最多允许 Burst 个突发请求
+func NewTokenBucket(store Store, rate Rate, opts ...Option) *Limiter {
+	return newLimiter(store, rate, "tb", store.TakeTokens, opts)
+}
+
+// NewSlidingWindow 创建滑动窗口限流器 任意一个 Period 内的请求数不超过 Limit
+func NewSlidingWindow(store Store, rate Rate, opts ...Option) *Limiter {
+	return newLimiter(store, rate, "sw", store.SlidingWindow, opts)
+}
+
+func newLimiter(store Store, rate Rate, algorithm string, fn func(context.Context, string, Rate, int, time.Time) (*Result, error), opts []Option) *Limiter {
+	if rate.Limit <= 0 || rate.Period < time.Millisecond {
+		panic("ratelimit: Limit 必须大于0 Period 不能小于1毫秒")
+	}
+	l := &Limiter{
+		store:     store,
+		rate:      rate,
+		name:      fmt.Sprintf("%s:%d:%d:%d", algorithm, rate.Limit, rate.Period.Milliseconds(), rate.Burst),
+		algorithm: fn,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Rate 限流器的速率
+func (l *Limiter) Rate() Rate {
+	return l.rate
+}
+
+// Allow 请求一次
+func (l *Limiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求n次 例如按请求的数据量计数
+func (l *Limiter) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	return l.algorithm(ctx, l.name+":"+key, l.rate, n, time.Now())
+}
+
+// bucketResult 根据取令牌后桶中剩余的令牌数生成结果
+func bucketResult(rate Rate, allowed bool, tokens float64, n int) *Result {
+	perMs := rate.perMillisecond()
+	capacity := rate.capacity()
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     capacity,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     milliseconds((float64(capacity) - tokens) / perMs),
+	}
+	if !allowed {
+		res.RetryAfter = milliseconds((float64(n) - tokens) / perMs)
+	}
+	return res
+}
+
+// windowResult 根据上一个窗口和当前窗口的计数生成结果 elapsed 为当前窗口已经过的毫秒数
+func windowResult(rate Rate, allowed bool, prev, cur float64, elapsed int64, n int) *Result {
+	window := float64(rate.Period.Milliseconds())
+	limit := float64(rate.Limit)
+	count := prev*(window-float64(elapsed))/window + cur
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: max(rate.Limit-int(math.Ceil(count)), 0),
+		Reset:     milliseconds(window - float64(elapsed)),
+	}
+	if allowed {
+		return res
+	}
+	if need := limit - cur - float64(n); need >= 0 && prev > 0 {
+		// 等待上一个窗口的权重降到 need/prev
+		res.RetryAfter = milliseconds(window*(1-need/prev) - float64(elapsed))
+		return res
+	}
+	// 当前窗口已满 等到下一个窗口中当前窗口的权重足够低
+	res.RetryAfter = res.Reset
+	if cur > 0 {
+		if w := (limit - float64(n)) / cur; w < 1 {
+			res.RetryAfter += milliseconds(window * (1 - max(w, 0)))
+		}
+	}
+	return res
+}
+
+func milliseconds(ms float64) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+// stores 使用相同的用例测试内存和redis存储
+func stores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  newRedisStore(t),
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testTokenBucket(t, s)
+		})
+	}
+}
+
+func testTokenBucket(t *testing.T, s Store) {
+	ctx := context.Background()
+	rate := Rate{Limit: 10, Period: time.Second, Burst: 3}
+	now := time.UnixMilli(1_000_000)
+
+	for i := 0; i < 3; i++ {
+		res, _ := s.TakeTokens(ctx, "k", rate, 1, now)
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+	res, _ := s.TakeTokens(ctx, "k", rate, 1, now)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond {
+		t.Fatalf("over burst = %+v", res)
+	}
+	res, _ = s.TakeTokens(ctx, "k", rate, 1, now.Add(100*time.Millisecond))
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after refill = %+v", res)
+	}
+	res, _ = s.TakeTokens(ctx, "k", rate, 1, now.Add(time.Hour))
+	if !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("refill is capped at burst: %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testSlidingWindow(t, s)
+		})
+	}
+}
+
+func testSlidingWindow(t *testing.T, s Store) {
+	ctx := context.Background()
+	rate := PerMinute(4)
+	start := time.UnixMilli(60_000 * 100)
+
+	for i := 0; i < 4; i++ {
+		if res, _ := s.SlidingWindow(ctx, "k", rate, 1, start.Add(50*time.Second)); !res.Allowed {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+	res, _ := s.SlidingWindow(ctx, "k", rate, 1, start.Add(55*time.Second))
+	if res.Allowed || res.Reset != 5*time.Second {
+		t.Fatalf("window full = %+v", res)
+	}
+
+	// 下一个窗口开始15秒 上一个窗口的4次请求按75%计算为3次
+	res, _ = s.SlidingWindow(ctx, "k", rate, 1, start.Add(75*time.Second))
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("weighted = %+v", res)
+	}
+	res, _ = s.SlidingWindow(ctx, "k", rate, 1, start.Add(75*time.Second))
+	if res.Allowed || res.RetryAfter != 15*time.Second {
+		t.Fatalf("weighted full = %+v", res)
+	}
+	if res, _ := s.SlidingWindow(ctx, "k", rate, 1, start.Add(10*time.Minute)); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("expired windows = %+v", res)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewSlidingWindow(NewMemoryStore(), PerMinute(2))
+	engine := gin.New()
+	engine.POST("/sms", l.Middleware(WithKeyFunc(Compose(ByRoute, ByIP))), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sms", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := send("10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d", i, w.Code)
+		}
+	}
+	w := send("10.0.0.1")
+	res := &web.Response{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+	if w.Code != http.StatusTooManyRequests || res.Code != web.TooManyRequests.Code || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("limited status = %d, headers = %v", w.Code, w.Header())
+	}
+	if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("headers = %v", w.Header())
+	}
+	if w := send("10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("other ip status = %d", w.Code)
+	}
+}
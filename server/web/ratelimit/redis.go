@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix redis key的默认前缀
+const DefaultPrefix = "ratelimit:"
+
+// tokenBucketScript 令牌桶 令牌数和上次更新时间保存在hash中
+// KEYS: 桶 ARGV: 容量 每毫秒生成的令牌数 当前毫秒时间 取出的令牌数
+// 返回: 是否允许 剩余令牌数 浮点数以字符串返回
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript 滑动窗口 每个窗口的计数保存在单独的key中
+// KEYS: 当前窗口 上一个窗口 ARGV: 限制 窗口毫秒数 当前窗口已经过的毫秒数 请求数
+// 返回: 是否允许 上一个窗口的计数 当前窗口的计数
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local size = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local cur = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local count = prev * (size - elapsed) / size + cur
+local allowed = 0
+if count + n <= limit then
+	cur = redis.call('INCRBY', KEYS[1], n)
+	redis.call('PEXPIRE', KEYS[1], size * 2)
+	allowed = 1
+end
+return {allowed, prev, cur}
+`)
+
+// RedisStore 使用redis的lua脚本原子地更新限流状态 用于多实例部署
+type RedisStore struct {
+	Client redis.UniversalClient
+	Prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{
+		Client: client,
+		Prefix: DefaultPrefix,
+	}
+}
+
+// TakeTokens 实现 Store
+func (s *RedisStore) TakeTokens(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error) {
+	res, err := tokenBucketScript.Run(ctx, s.Client, []string{s.Prefix + key},
+		rate.capacity(),
+		strconv.FormatFloat(rate.perMillisecond(), 'g', -1, 64),
+		now.UnixMilli(),
+		n,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := strconv.ParseFloat(res[1].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+	return bucketResult(rate, res[0].(int64) == 1, tokens, n), nil
+}
+
+// SlidingWindow 实现 Store 两个窗口的key使用相同的hash tag 集群模式下在同一个slot
+func (s *RedisStore) SlidingWindow(ctx context.Context, key string, rate Rate, n int, now time.Time) (*Result, error) {
+	ms := now.UnixMilli()
+	size := rate.Period.Milliseconds()
+	index, elapsed := ms/size, ms%size
+	base := s.Prefix + "{" + key + "}:"
+	keys := []string{base + strconv.FormatInt(index, 10), base + strconv.FormatInt(index-1, 10)}
+	res, err := slidingWindowScript.Run(ctx, s.Client, keys, rate.Limit, size, elapsed, n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return windowResult(rate, res[0] == 1, float64(res[1]), float64(res[2]), elapsed, n), nil
+}
+
+// HealthCheck ping redis 实现 health.HealthChecker
+func (s *RedisStore) HealthCheck(ctx context.Context) error {
+	return s.Client.Ping(ctx).Err()
+}
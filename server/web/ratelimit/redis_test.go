@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisStore(client), mr
+}
+
+func newRedisStore(t *testing.T) Store {
+	s, _ := newRedis(t)
+	return s
+}
+
+func TestRedisTokenBucketExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newRedis(t)
+	rate := Rate{Limit: 10, Period: time.Second, Burst: 3}
+	now := time.UnixMilli(1_000_000)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.TakeTokens(ctx, "k", rate, 1, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 桶装满需要300毫秒 再多保留1秒
+	if ttl := mr.TTL(DefaultPrefix + "k"); ttl != 1300*time.Millisecond {
+		t.Errorf("ttl = %v", ttl)
+	}
+	if tokens := mr.HGet(DefaultPrefix+"k", "tokens"); tokens != "0" {
+		t.Errorf("tokens = %q", tokens)
+	}
+
+	// 过期时桶已经装满 删除key等同于满桶
+	mr.FastForward(1300 * time.Millisecond)
+	if mr.Exists(DefaultPrefix + "k") {
+		t.Fatal("bucket did not expire")
+	}
+	res, err := s.TakeTokens(ctx, "k", rate, 1, now.Add(1300*time.Millisecond))
+	if err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("after expiry = %+v, %v", res, err)
+	}
+}
+
+func TestRedisSlidingWindowExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newRedis(t)
+	s.Prefix = "test:"
+	rate := PerMinute(4)
+	start := time.UnixMilli(60_000 * 100)
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.SlidingWindow(ctx, "k", rate, 1, start.Add(50*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 被拒绝的请求不计数
+	if res, err := s.SlidingWindow(ctx, "k", rate, 1, start.Add(55*time.Second)); err != nil || res.Allowed {
+		t.Fatalf("window full = %+v, %v", res, err)
+	}
+	if count, _ := mr.Get("test:{k}:100"); count != "4" {
+		t.Errorf("count = %q", count)
+	}
+	// 窗口的key保留两个周期 作为下一个窗口的上一个窗口
+	if ttl := mr.TTL("test:{k}:100"); ttl != 2*time.Minute {
+		t.Errorf("ttl = %v", ttl)
+	}
+
+	if res, err := s.SlidingWindow(ctx, "k", rate, 1, start.Add(75*time.Second)); err != nil || !res.Allowed {
+		t.Fatalf("next window = %+v, %v", res, err)
+	}
+	if count, _ := mr.Get("test:{k}:101"); count != "1" {
+		t.Errorf("next count = %q", count)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys after expiry = %v", keys)
+	}
+}
+
+func TestRedisFailClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newRedis(t)
+	l := NewTokenBucket(s, PerSecond(1))
+	mr.Close()
+	if err := s.HealthCheck(context.Background()); err == nil {
+		t.Fatal("health check succeeded without redis")
+	}
+
+	for _, tt := range []struct {
+		name   string
+		opts   []MiddlewareOption
+		status int
+	}{
+		{"open", nil, http.StatusOK},
+		{"closed", []MiddlewareOption{WithFailClosed()}, http.StatusInternalServerError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.GET("/", l.Middleware(tt.opts...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d; want %d", w.Code, tt.status)
+			}
+			if w.Header().Get("RateLimit-Limit") != "" {
+				t.Errorf("headers = %v", w.Header())
+			}
+		})
+	}
+}
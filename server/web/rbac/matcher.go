@@ -0,0 +1,109 @@
+package rbac
+
+import "strings"
+
+// matcher 加载到内存中的策略 创建后只读
+type matcher struct {
+	subjects map[string][]string
+	parents  map[string][]string
+	rules    map[string][]rule
+}
+
+// rule 编译后的路由权限
+type rule struct {
+	method   string
+	segments []string
+	// rest 最后一段为 * 或 *name 时匹配之后的所有路径
+	rest bool
+}
+
+func newMatcher(p *Policy) *matcher {
+	m := &matcher{
+		subjects: make(map[string][]string),
+		parents:  make(map[string][]string),
+		rules:    make(map[string][]rule),
+	}
+	for _, s := range p.Subjects {
+		m.subjects[s.Subject] = append(m.subjects[s.Subject], s.Role)
+	}
+	for _, r := range p.Parents {
+		m.parents[r.Role] = append(m.parents[r.Role], r.Parent)
+	}
+	for _, perm := range p.Permissions {
+		m.rules[perm.Role] = append(m.rules[perm.Role], compile(perm.Method, perm.Path))
+	}
+	return m
+}
+
+func compile(method, path string) rule {
+	r := rule{method: strings.ToUpper(method), segments: splitPath(path)}
+	if r.method == "" {
+		r.method = "*"
+	}
+	if n := len(r.segments); n > 0 && strings.HasPrefix(r.segments[n-1], "*") {
+		r.segments = r.segments[:n-1]
+		r.rest = true
+	}
+	return r
+}
+
+// allow 拥有roles的主体是否可以访问路由
+func (m *matcher) allow(roles []string, method, path string) bool {
+	if len(roles) == 0 {
+		return false
+	}
+	method = strings.ToUpper(method)
+	segments := splitPath(path)
+	for _, role := range m.expand(roles) {
+		for _, r := range m.rules[role] {
+			if r.match(method, segments) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expand 展开继承的角色 忽略循环继承
+func (m *matcher) expand(roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	result := make([]string, 0, len(roles))
+	queue := append([]string(nil), roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		result = append(result, role)
+		queue = append(queue, m.parents[role]...)
+	}
+	return result
+}
+
+func (r rule) match(method string, segments []string) bool {
+	if r.method != "*" && r.method != method {
+		return false
+	}
+	if len(segments) < len(r.segments) || (!r.rest && len(segments) != len(r.segments)) {
+		return false
+	}
+	for i, s := range r.segments {
+		if s == "*" || strings.HasPrefix(s, ":") {
+			continue
+		}
+		if s != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	subject func(c *gin.Context) string
+	roles   func(c *gin.Context) []string
+}
+
+// WithSubjectFunc 设置获取当前主体的函数 默认使用 web.GetUserID
+func WithSubjectFunc(fn func(c *gin.Context) string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.subject = fn
+	}
+}
+
+// WithRolesFunc 从请求中获取当前主体的角色 例如token中保存的角色 设置后不再使用 Store 中分配的角色
+func WithRolesFunc(fn func(c *gin.Context) []string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.roles = fn
+	}
+}
+
+// Middleware 鉴权中间件 需要在认证中间件之后使用 使用请求的方法和路由模板匹配权限
+// 例如 /api/users/:id 没有匹配的路由时使用请求的路径
+// 没有当前主体时返回 web.UNAUTHORIZED 没有权限时返回 web.Forbidden
+func (e *Enforcer) Middleware(opts ...MiddlewareOption) gin.HandlerFunc {
+	o := &middlewareOptions{subject: web.GetUserID}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		method, path := c.Request.Method, c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		var allowed bool
+		if o.roles != nil {
+			allowed = e.EnforceRoles(o.roles(c), method, path)
+		} else {
+			subject := o.subject(c)
+			if subject == "" {
+				web.NewGinActionImpl(c).ThrowError(web.UNAUTHORIZED)
+				return
+			}
+			allowed = e.Enforce(subject, method, path)
+		}
+		if !allowed {
+			web.NewGinActionImpl(c).ThrowError(web.Forbidden)
+			return
+		}
+		c.Next()
+	}
+}
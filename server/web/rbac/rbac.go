@@ -0,0 +1,239 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lshaofan/cb-framework/server"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCacheSize 默认缓存的鉴权结果数量
+const DefaultCacheSize = 10000
+
+type Option func(*Enforcer)
+
+// WithRefreshInterval 作为服务运行时定时从 Store 重新加载策略 用于多实例部署时同步其他实例的修改 默认不定时加载
+func WithRefreshInterval(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.refresh = d
+	}
+}
+
+// WithCacheSize 设置缓存的鉴权结果数量 为0时不缓存
+func WithCacheSize(size int) Option {
+	return func(e *Enforcer) {
+		e.cacheSize = size
+	}
+}
+
+// WithLogger 设置定时加载失败时使用的日志 默认使用 logrus 的标准日志
+func WithLogger(l logrus.FieldLogger) Option {
+	return func(e *Enforcer) {
+		e.logger = l
+	}
+}
+
+// Enforcer 基于角色的权限控制 策略保存在 Store 中 加载到内存后匹配
+// 修改策略的方法会先写入 Store 再重新加载 也可以作为服务运行 在 SIGHUP 或定时器触发时重新加载
+type Enforcer struct {
+	store     Store
+	refresh   time.Duration
+	cacheSize int
+	logger    logrus.FieldLogger
+
+	mu      sync.RWMutex
+	matcher *matcher
+	cache   map[cacheKey]bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type cacheKey struct {
+	subject string
+	method  string
+	path    string
+}
+
+// New 创建权限控制 使用前需要调用 Load 或作为服务启动
+func New(store Store, opts ...Option) *Enforcer {
+	e := &Enforcer{
+		store:     store,
+		cacheSize: DefaultCacheSize,
+		logger:    logrus.StandardLogger(),
+		matcher:   newMatcher(&Policy{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.cache = make(map[cacheKey]bool)
+	return e
+}
+
+// Load 从 Store 加载策略 加载失败时继续使用之前的策略
+func (e *Enforcer) Load(ctx context.Context) error {
+	policy, err := e.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	m := newMatcher(policy)
+	e.mu.Lock()
+	e.matcher = m
+	e.cache = make(map[cacheKey]bool)
+	e.mu.Unlock()
+	return nil
+}
+
+// Enforce 判断主体是否可以访问路由
+func (e *Enforcer) Enforce(subject, method, path string) bool {
+	key := cacheKey{subject: subject, method: method, path: path}
+	e.mu.RLock()
+	allowed, ok := e.cache[key]
+	m := e.matcher
+	e.mu.RUnlock()
+	if ok {
+		return allowed
+	}
+
+	allowed = m.allow(m.subjects[subject], method, path)
+	if e.cacheSize > 0 {
+		e.mu.Lock()
+		// 只缓存当前的策略 加载期间的结果可能已过期
+		if e.matcher == m {
+			if len(e.cache) >= e.cacheSize {
+				e.cache = make(map[cacheKey]bool)
+			}
+			e.cache[key] = allowed
+		}
+		e.mu.Unlock()
+	}
+	return allowed
+}
+
+// EnforceRoles 判断拥有roles的主体是否可以访问路由 用于角色保存在token等场景 不使用缓存
+func (e *Enforcer) EnforceRoles(roles []string, method, path string) bool {
+	e.mu.RLock()
+	m := e.matcher
+	e.mu.RUnlock()
+	return m.allow(roles, method, path)
+}
+
+// Roles 主体直接拥有的角色
+func (e *Enforcer) Roles(subject string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]string(nil), e.matcher.subjects[subject]...)
+}
+
+// ImplicitRoles 主体拥有的角色 包括继承的角色
+func (e *Enforcer) ImplicitRoles(subject string) []string {
+	e.mu.RLock()
+	m := e.matcher
+	e.mu.RUnlock()
+	return m.expand(m.subjects[subject])
+}
+
+// HasRole 主体是否拥有角色 包括继承的角色
+func (e *Enforcer) HasRole(subject, role string) bool {
+	for _, r := range e.ImplicitRoles(subject) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPermission 允许角色访问路由
+func (e *Enforcer) AddPermission(ctx context.Context, role, method, path string) error {
+	return e.update(ctx, e.store.AddPermission(ctx, Permission{Role: role, Method: strings.ToUpper(method), Path: path}))
+}
+
+// RemovePermission 删除角色的路由权限
+func (e *Enforcer) RemovePermission(ctx context.Context, role, method, path string) error {
+	return e.update(ctx, e.store.RemovePermission(ctx, Permission{Role: role, Method: strings.ToUpper(method), Path: path}))
+}
+
+// AddParent 设置 role 继承 parent 的权限
+func (e *Enforcer) AddParent(ctx context.Context, role, parent string) error {
+	if role == parent {
+		return errors.New("rbac: 角色不能继承自己")
+	}
+	return e.update(ctx, e.store.AddParent(ctx, role, parent))
+}
+
+// RemoveParent 取消角色继承
+func (e *Enforcer) RemoveParent(ctx context.Context, role, parent string) error {
+	return e.update(ctx, e.store.RemoveParent(ctx, role, parent))
+}
+
+// AssignRole 给主体分配角色
+func (e *Enforcer) AssignRole(ctx context.Context, subject, role string) error {
+	return e.update(ctx, e.store.AssignRole(ctx, subject, role))
+}
+
+// UnassignRole 取消主体的角色
+func (e *Enforcer) UnassignRole(ctx context.Context, subject, role string) error {
+	return e.update(ctx, e.store.UnassignRole(ctx, subject, role))
+}
+
+func (e *Enforcer) update(ctx context.Context, err error) error {
+	if err != nil {
+		return err
+	}
+	return e.Load(ctx)
+}
+
+// Name 实现 server.Namer
+func (e *Enforcer) Name() string {
+	return "rbac"
+}
+
+// Init 实现 server.Service 加载策略
+func (e *Enforcer) Init(env server.Environment) error {
+	return e.Load(context.Background())
+}
+
+// Start 实现 server.Service 配置了 WithRefreshInterval 时定时加载策略
+func (e *Enforcer) Start() error {
+	if e.refresh <= 0 {
+		return nil
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				if err := e.Load(context.Background()); err != nil {
+					e.logger.WithError(err).Error("rbac: 加载权限策略失败")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 实现 server.Service
+func (e *Enforcer) Stop() error {
+	if e.stop == nil {
+		return nil
+	}
+	close(e.stop)
+	<-e.done
+	e.stop = nil
+	return nil
+}
+
+// Reload 实现 server.Reloader
+func (e *Enforcer) Reload() error {
+	return e.Load(context.Background())
+}
@@ -0,0 +1,181 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server/web"
+)
+
+// memoryStore 测试使用的内存存储
+type memoryStore struct {
+	policy Policy
+}
+
+func (s *memoryStore) Load(ctx context.Context) (*Policy, error) {
+	p := s.policy
+	return &p, nil
+}
+
+func (s *memoryStore) AddPermission(ctx context.Context, p Permission) error {
+	s.policy.Permissions = append(s.policy.Permissions, p)
+	return nil
+}
+
+func (s *memoryStore) RemovePermission(ctx context.Context, p Permission) error {
+	list := s.policy.Permissions[:0]
+	for _, v := range s.policy.Permissions {
+		if v.Role != p.Role || v.Method != p.Method || v.Path != p.Path {
+			list = append(list, v)
+		}
+	}
+	s.policy.Permissions = list
+	return nil
+}
+
+func (s *memoryStore) AddParent(ctx context.Context, role, parent string) error {
+	s.policy.Parents = append(s.policy.Parents, RoleParent{Role: role, Parent: parent})
+	return nil
+}
+
+func (s *memoryStore) RemoveParent(ctx context.Context, role, parent string) error {
+	list := s.policy.Parents[:0]
+	for _, v := range s.policy.Parents {
+		if v.Role != role || v.Parent != parent {
+			list = append(list, v)
+		}
+	}
+	s.policy.Parents = list
+	return nil
+}
+
+func (s *memoryStore) AssignRole(ctx context.Context, subject, role string) error {
+	s.policy.Subjects = append(s.policy.Subjects, SubjectRole{Subject: subject, Role: role})
+	return nil
+}
+
+func (s *memoryStore) UnassignRole(ctx context.Context, subject, role string) error {
+	list := s.policy.Subjects[:0]
+	for _, v := range s.policy.Subjects {
+		if v.Subject != subject || v.Role != role {
+			list = append(list, v)
+		}
+	}
+	s.policy.Subjects = list
+	return nil
+}
+
+func newEnforcer(t *testing.T) *Enforcer {
+	t.Helper()
+	ctx := context.Background()
+	e := New(&memoryStore{})
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(e.AddPermission(ctx, "viewer", "GET", "/api/users"))
+	must(e.AddPermission(ctx, "viewer", "GET", "/api/users/:id"))
+	must(e.AddPermission(ctx, "editor", "*", "/api/users/:id"))
+	must(e.AddPermission(ctx, "admin", "*", "/api/*"))
+	must(e.AddParent(ctx, "editor", "viewer"))
+	// 循环继承不会导致死循环
+	must(e.AddParent(ctx, "viewer", "editor"))
+	must(e.AssignRole(ctx, "1", "viewer"))
+	must(e.AssignRole(ctx, "2", "editor"))
+	must(e.AssignRole(ctx, "3", "admin"))
+	return e
+}
+
+func TestEnforce(t *testing.T) {
+	e := newEnforcer(t)
+	tests := []struct {
+		subject, method, path string
+		allowed               bool
+	}{
+		{"1", "GET", "/api/users", true},
+		{"1", "GET", "/api/users/5", true},
+		{"1", "DELETE", "/api/users/5", true},
+		{"2", "DELETE", "/api/users/5", true},
+		{"2", "GET", "/api/users", true},
+		{"2", "GET", "/api/users/5/orders", false},
+		{"3", "POST", "/api/orders/1/items", true},
+		{"3", "GET", "/admin", false},
+		{"4", "GET", "/api/users", false},
+	}
+	for _, tt := range tests {
+		if got := e.Enforce(tt.subject, tt.method, tt.path); got != tt.allowed {
+			t.Errorf("Enforce(%s, %s, %s) = %v", tt.subject, tt.method, tt.path, got)
+		}
+	}
+	if !e.HasRole("2", "viewer") || e.HasRole("1", "admin") {
+		t.Errorf("roles = %v", e.ImplicitRoles("2"))
+	}
+}
+
+func TestUpdateClearsCache(t *testing.T) {
+	ctx := context.Background()
+	e := newEnforcer(t)
+	if !e.Enforce("3", "GET", "/api/users") {
+		t.Fatal("admin should be allowed")
+	}
+	if err := e.UnassignRole(ctx, "3", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if e.Enforce("3", "GET", "/api/users") {
+		t.Error("cached result after role removal")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := newEnforcer(t)
+	engine := gin.New()
+	// 编码的 / 不会绕过路由模板的权限
+	engine.UseRawPath = true
+	engine.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			web.SetUserID(c, id)
+		}
+	}, e.Middleware())
+	engine.GET("/api/users", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.POST("/api/users", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/api/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/api/users/:id/orders", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		user, method, target string
+		status               int
+	}{
+		{"", "GET", "/api/users", http.StatusUnauthorized},
+		{"1", "GET", "/api/users", http.StatusOK},
+		{"1", "POST", "/api/users", http.StatusForbidden},
+		{"3", "POST", "/api/users", http.StatusOK},
+		{"1", "GET", "/api/users/5", http.StatusOK},
+		{"1", "GET", "/api/users/a%2Fb", http.StatusOK},
+		{"2", "GET", "/api/users/5/orders", http.StatusForbidden},
+		// 没有匹配的路由时使用请求的路径
+		{"1", "GET", "/api/unknown", http.StatusForbidden},
+		{"3", "GET", "/api/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set("X-User", tt.user)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s %s status = %d", tt.user, tt.method, tt.target, w.Code)
+		}
+	}
+}
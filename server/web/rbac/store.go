@@ -0,0 +1,129 @@
+package rbac
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Permission 角色可以访问的路由 Method 为 * 时匹配所有方法
+// Path 支持 :name 和 * 匹配一段路径 以 /* 或 /*name 结尾时匹配之后的所有路径
+type Permission struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Role   string `gorm:"size:64;not null;uniqueIndex:idx_rbac_permission" json:"role"`
+	Method string `gorm:"size:16;not null;uniqueIndex:idx_rbac_permission" json:"method"`
+	Path   string `gorm:"size:255;not null;uniqueIndex:idx_rbac_permission" json:"path"`
+}
+
+func (Permission) TableName() string {
+	return "rbac_permissions"
+}
+
+// RoleParent 角色继承 Role 拥有 Parent 的所有权限
+type RoleParent struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Role   string `gorm:"size:64;not null;uniqueIndex:idx_rbac_role_parent" json:"role"`
+	Parent string `gorm:"size:64;not null;uniqueIndex:idx_rbac_role_parent" json:"parent"`
+}
+
+func (RoleParent) TableName() string {
+	return "rbac_role_parents"
+}
+
+// SubjectRole 主体拥有的角色 主体一般为用户id
+type SubjectRole struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	Subject string `gorm:"size:64;not null;uniqueIndex:idx_rbac_subject_role" json:"subject"`
+	Role    string `gorm:"size:64;not null;uniqueIndex:idx_rbac_subject_role" json:"role"`
+}
+
+func (SubjectRole) TableName() string {
+	return "rbac_subject_roles"
+}
+
+// Policy 全部的权限策略
+type Policy struct {
+	Permissions []Permission
+	Parents     []RoleParent
+	Subjects    []SubjectRole
+}
+
+// Store 权限策略的存储
+type Store interface {
+	Load(ctx context.Context) (*Policy, error)
+	AddPermission(ctx context.Context, p Permission) error
+	RemovePermission(ctx context.Context, p Permission) error
+	AddParent(ctx context.Context, role, parent string) error
+	RemoveParent(ctx context.Context, role, parent string) error
+	AssignRole(ctx context.Context, subject, role string) error
+	UnassignRole(ctx context.Context, subject, role string) error
+}
+
+// GormStore 使用gorm保存权限策略
+type GormStore struct {
+	DB *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{DB: db}
+}
+
+// Migrate 创建权限策略的表
+func (s *GormStore) Migrate() error {
+	return s.DB.AutoMigrate(&Permission{}, &RoleParent{}, &SubjectRole{})
+}
+
+// Load 实现 Store
+func (s *GormStore) Load(ctx context.Context) (*Policy, error) {
+	p := &Policy{}
+	db := s.DB.WithContext(ctx)
+	if err := db.Find(&p.Permissions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Find(&p.Parents).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Find(&p.Subjects).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AddPermission 实现 Store 已存在时不重复添加
+func (s *GormStore) AddPermission(ctx context.Context, p Permission) error {
+	return s.DB.WithContext(ctx).
+		FirstOrCreate(&Permission{}, &Permission{Role: p.Role, Method: p.Method, Path: p.Path}).Error
+}
+
+// RemovePermission 实现 Store
+func (s *GormStore) RemovePermission(ctx context.Context, p Permission) error {
+	return s.DB.WithContext(ctx).
+		Where("role = ? AND method = ? AND path = ?", p.Role, p.Method, p.Path).
+		Delete(&Permission{}).Error
+}
+
+// AddParent 实现 Store
+func (s *GormStore) AddParent(ctx context.Context, role, parent string) error {
+	return s.DB.WithContext(ctx).
+		FirstOrCreate(&RoleParent{}, &RoleParent{Role: role, Parent: parent}).Error
+}
+
+// RemoveParent 实现 Store
+func (s *GormStore) RemoveParent(ctx context.Context, role, parent string) error {
+	return s.DB.WithContext(ctx).
+		Where("role = ? AND parent = ?", role, parent).
+		Delete(&RoleParent{}).Error
+}
+
+// AssignRole 实现 Store
+func (s *GormStore) AssignRole(ctx context.Context, subject, role string) error {
+	return s.DB.WithContext(ctx).
+		FirstOrCreate(&SubjectRole{}, &SubjectRole{Subject: subject, Role: role}).Error
+}
+
+// UnassignRole 实现 Store
+func (s *GormStore) UnassignRole(ctx context.Context, subject, role string) error {
+	return s.DB.WithContext(ctx).
+		Where("subject = ? AND role = ?", subject, role).
+		Delete(&SubjectRole{}).Error
+}
@@ -0,0 +1,81 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newGormStore(t *testing.T) *GormStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库每个连接独立 只使用一个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	s := NewGormStore(db)
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGormStore(t *testing.T) {
+	ctx := context.Background()
+	s := newGormStore(t)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() *Policy {
+		t.Helper()
+		p, err := s.Load(ctx)
+		must(err)
+		return p
+	}
+
+	// 重复添加不会报错也不会重复保存
+	for i := 0; i < 2; i++ {
+		must(s.AddPermission(ctx, Permission{Role: "viewer", Method: "GET", Path: "/api/users"}))
+		must(s.AddPermission(ctx, Permission{Role: "viewer", Method: "GET", Path: "/api/users/:id"}))
+		must(s.AddParent(ctx, "editor", "viewer"))
+		must(s.AssignRole(ctx, "1", "editor"))
+	}
+	p := load()
+	if len(p.Permissions) != 2 || len(p.Parents) != 1 || len(p.Subjects) != 1 {
+		t.Fatalf("policy = %+v", p)
+	}
+	if got := p.Parents[0]; got.Role != "editor" || got.Parent != "viewer" {
+		t.Errorf("parent = %+v", got)
+	}
+	if got := p.Subjects[0]; got.Subject != "1" || got.Role != "editor" {
+		t.Errorf("subject = %+v", got)
+	}
+
+	// 加载的策略可以直接用于鉴权
+	e := New(s)
+	must(e.Load(ctx))
+	if !e.Enforce("1", "GET", "/api/users/5") {
+		t.Error("inherited permission was not loaded")
+	}
+
+	must(s.RemovePermission(ctx, Permission{Role: "viewer", Method: "GET", Path: "/api/users"}))
+	must(s.RemoveParent(ctx, "editor", "viewer"))
+	must(s.UnassignRole(ctx, "1", "editor"))
+	p = load()
+	if len(p.Permissions) != 1 || p.Permissions[0].Path != "/api/users/:id" || len(p.Parents) != 0 || len(p.Subjects) != 0 {
+		t.Errorf("policy = %+v", p)
+	}
+}
@@ -2,10 +2,14 @@ package web
 
 import (
 	"errors"
+	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/zh"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
 	zh_translations "github.com/go-playground/validator/v10/translations/zh"
 	"net/http"
 	"reflect"
@@ -24,6 +28,11 @@ type Validate struct {
 	uni      *ut.UniversalTranslator
 	validate *validator.Validate
 	trans    ut.Translator
+
+	mu      sync.RWMutex
+	locales map[string]ut.Translator
+	scenes  map[reflect.Type]map[string][]string
+	binding *sceneValidator
 }
 
 var (
@@ -31,72 +40,238 @@ var (
 	validateOnce sync.Once
 )
 
+// NewValidate 获取全局的校验器 第一次调用时初始化
+// 初始化时会把 gin 的 binding.Validator 替换为支持场景校验的包装 这样 WithScene 和 WithBindScene 才能作用于gin的绑定方法
+// 包装把没有使用场景的校验委托给原来的 binding.Validator 校验行为不变
+// 需要自定义 binding.Validator 时应在第一次调用 NewValidate 之前设置 其 Engine 必须返回 *validator.Validate
+// 之后再替换 binding.Validator 会使场景校验失效
 func NewValidate() *Validate {
 	validateOnce.Do(func() {
-		validate = &Validate{}
+		validate = &Validate{locales: make(map[string]ut.Translator)}
 		//注册翻译器
 		zh_ := zh.New()
 		uni := ut.New(zh_, zh_)
-		trans, _ := uni.GetTranslator("zh")
 		//获取gin的校验器
 		val := binding.Validator.Engine().(*validator.Validate)
-		//注册翻译器
-		_ = zh_translations.RegisterDefaultTranslations(val, trans)
 		validate.validate = val
 		validate.uni = uni
-		validate.trans = trans
+		// 替换gin的校验器以支持场景校验
+		validate.binding = &sceneValidator{StructValidator: binding.Validator, validate: validate}
+		binding.Validator = validate.binding
+		_ = validate.RegisterTranslator(zh_, zh_translations.RegisterDefaultTranslations)
+		_ = validate.RegisterTranslator(en.New(), en_translations.RegisterDefaultTranslations)
+		validate.trans, _ = uni.GetTranslator("zh")
 	})
 	return validate
 }
 
+// RegisterTranslator 注册一种语言的校验错误翻译 register 通常为 validator/v10/translations 下对应语言的 RegisterDefaultTranslations
+// 默认已经注册了 zh 和 en
+func (v *Validate) RegisterTranslator(translator locales.Translator, register func(*validator.Validate, ut.Translator) error) error {
+	if err := v.uni.AddTranslator(translator, true); err != nil {
+		return err
+	}
+	trans, _ := v.uni.GetTranslator(translator.Locale())
+	if err := register(v.validate, trans); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	v.locales[translator.Locale()] = trans
+	v.mu.Unlock()
+	messages.mu.Lock()
+	messages.invalidate()
+	messages.mu.Unlock()
+	return nil
+}
+
+// Translator 获取语言对应的校验错误翻译器 语言未注册时返回默认的中文翻译器
+func (v *Validate) Translator(locale string) ut.Translator {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if trans, ok := v.locales[locale]; ok {
+		return trans
+	}
+	return v.trans
+}
+
+// Locales 已注册校验错误翻译的语言
+func (v *Validate) Locales() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	locales := make([]string, 0, len(v.locales))
+	for locale := range v.locales {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// ValidateStruct 使用gin的校验器校验结构体 校验规则为binding标签
+func (v *Validate) ValidateStruct(obj any) error {
+	return v.validate.Struct(obj)
+}
+
 type Request struct {
 	validateTags []string
+	allErrors    bool
+	locale       string
+}
+
+type RequestOption func(*Request)
+
+// WithAllErrors 返回所有校验失败的字段 ErrorModel.Result 为 字段路径 -> 错误信息 的map
+// 字段路径使用json或form等标签名 例如 items[2].price Message 仍然为第一个错误信息
+func WithAllErrors() RequestOption {
+	return func(r *Request) {
+		r.allErrors = true
+	}
+}
+
+// WithLocale 使用指定语言翻译校验错误 默认为 DefaultLocale
+func WithLocale(locale string) RequestOption {
+	return func(r *Request) {
+		r.locale = locale
+	}
+}
+
+// allErrorsKey gin上下文中开启返回所有校验错误的key
+const allErrorsKey = "web.allValidationErrors"
+
+// AllValidationErrors 中间件 之后的 GinActionImpl 和 Handle 校验失败时返回所有字段的错误 见 WithAllErrors
+func AllValidationErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(allErrorsKey, true)
+		c.Next()
+	}
 }
 
 // GetValidateErr 获取校验错误信息 传入错误对象和对象 对象tag为json form uri query header
 func (r *Request) GetValidateErr(err error, obj interface{}) *ErrorModel {
 	v := NewValidate()
-	getObj := reflect.TypeOf(obj)
-	var result *ErrorModel
 	var errs validator.ValidationErrors
 	// 判断err 是否是 validator.ValidationErrors 类型
-	if !errors.As(err, &errs) {
-		result = NewErrorModel(ERROR, err.Error(), nil, http.StatusPreconditionFailed)
-	} else {
-
-		for _, err := range errs {
-			if f, exist := getObj.Elem().FieldByName(err.Field()); exist {
-				var tag string
-				for _, tagValueStr := range r.validateTags {
-					tagStr, ok := f.Tag.Lookup(tagValueStr)
-					if ok {
-						tag = tagStr
-						break
-					}
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		return NewErrorModel(ERROR, err.Error(), nil, http.StatusPreconditionFailed)
+	}
+
+	result := NewErrorModel(ERROR, "", nil, http.StatusPreconditionFailed)
+	fields := make(map[string][]string)
+	for _, e := range errs {
+		path, name := r.fieldPath(reflect.TypeOf(obj), e.Namespace())
+		message := e.Translate(v.Translator(r.locale))
+		// 规则没有当前语言的翻译时使用默认语言
+		if message == e.Error() {
+			message = e.Translate(v.Translator(DefaultLocale))
+		}
+		if name != "" {
+			message = name + strings.Replace(message, e.Field(), "", -1)
+		}
+		if result.Message == "" {
+			result.Message = message
+			if !r.allErrors {
+				return result
+			}
+		}
+		fields[path] = append(fields[path], message)
+	}
+	result.Result = fields
+	return result
+}
+
+// fieldPath 把校验错误的Namespace转换为使用标签名的字段路径 例如 Order.Items[2].Price 转换为 items[2].price
+// name 为最后一个字段的标签名 字段没有标签时为空
+func (r *Request) fieldPath(t reflect.Type, namespace string) (path, name string) {
+	segments := splitNamespace(namespace)
+	// 第一段为结构体的类型名
+	if len(segments) > 0 {
+		segments = segments[1:]
+	}
+	var parts []string
+	for _, seg := range segments {
+		field, index := seg, ""
+		if i := strings.Index(seg, "["); i >= 0 {
+			field, index = seg[:i], seg[i:]
+		}
+		name = ""
+		t = indirect(t)
+		if t != nil && t.Kind() == reflect.Struct {
+			if f, ok := t.FieldByName(field); ok {
+				name = r.tagName(f)
+				t = f.Type
+				// 没有标签的嵌入结构体在json中是展开的
+				if f.Anonymous && name == "" {
+					continue
 				}
-				result = NewErrorModel(
-					ERROR,
-					tag+strings.Replace(err.Translate(v.trans), err.Field(), "", -1),
-					nil,
-					http.StatusPreconditionFailed,
-				)
 			} else {
-				result = NewErrorModel(
-					ERROR,
-					err.Translate(v.trans),
-					nil,
-					http.StatusPreconditionFailed,
-				)
+				t = nil
 			}
-			return result
+		} else {
+			t = nil
+		}
+		if name != "" {
+			field = name
 		}
+		for n := strings.Count(index, "["); n > 0 && t != nil; n-- {
+			switch t = indirect(t); t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				t = nil
+			}
+		}
+		parts = append(parts, field+index)
+	}
+	return strings.Join(parts, "."), name
+}
 
+// tagName 按 validateTags 的顺序获取字段的标签名
+func (r *Request) tagName(f reflect.StructField) string {
+	for _, key := range r.validateTags {
+		if tag, ok := f.Tag.Lookup(key); ok {
+			if tag = strings.Split(tag, ",")[0]; tag != "" && tag != "-" {
+				return tag
+			}
+		}
 	}
-	return result
+	return ""
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
 }
 
-func NewRequest() *Request {
-	return &Request{
+// splitNamespace 按.分割Namespace map的key中的.不分割
+func splitNamespace(namespace string) []string {
+	var (
+		segments []string
+		depth    int
+		start    int
+	)
+	for i, ch := range namespace {
+		switch ch {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, namespace[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, namespace[start:])
+}
+
+func NewRequest(opts ...RequestOption) *Request {
+	r := &Request{
 		validateTags: []string{"json", "form", "uri", "query", "header"},
+		locale:       DefaultLocale,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/core/requestid"
+)
+
+const (
+	// RequestIDHeader 请求id的默认请求头和响应头
+	RequestIDHeader = requestid.Header
+
+	// requestIDKey gin上下文中保存请求id的key
+	requestIDKey = "web.requestID"
+	// requestIDInBodyKey gin上下文中开启在 Response 中返回请求id的key
+	requestIDInBodyKey = "web.requestIDInBody"
+)
+
+// ContextWithRequestID 把请求id保存到ctx中 用于在异步任务等场景中传递请求id 见 requestid.NewContext
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.NewContext(ctx, id)
+}
+
+// RequestIDFromContext 获取ctx中的请求id 没有时返回空
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		return GetRequestID(c)
+	}
+	return requestid.FromContext(ctx)
+}
+
+// GetRequestID 获取当前请求的id 没有使用 RequestID 中间件时返回空
+func GetRequestID(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	if c.Request != nil {
+		return requestid.FromContext(c.Request.Context())
+	}
+	return ""
+}
+
+type RequestIDOption func(*requestIDOptions)
+
+type requestIDOptions struct {
+	header    string
+	generator func() string
+	inBody    bool
+}
+
+// WithRequestIDHeader 设置请求id的请求头和响应头 默认为 X-Request-ID
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(o *requestIDOptions) {
+		o.header = header
+	}
+}
+
+// WithRequestIDGenerator 设置生成请求id的函数 默认为32位的随机十六进制字符串
+func WithRequestIDGenerator(fn func() string) RequestIDOption {
+	return func(o *requestIDOptions) {
+		o.generator = fn
+	}
+}
+
+// WithRequestIDInResponse 同时在 Response 的 request_id 字段中返回请求id
+func WithRequestIDInResponse() RequestIDOption {
+	return func(o *requestIDOptions) {
+		o.inBody = true
+	}
+}
+
+// RequestID 请求id中间件 使用请求头中的id或生成新的id 保存到gin上下文和请求的ctx中 并在响应头中返回
+// 之后的 NewLogger Recovery AccessLog 会自动带上请求id
+// 微信客户端的方法没有ctx参数 需要使用 WithContext(c.Request.Context()) 获取的副本才会传递请求id
+func RequestID(opts ...RequestIDOption) gin.HandlerFunc {
+	o := &requestIDOptions{
+		header:    RequestIDHeader,
+		generator: newRequestID,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		id := c.GetHeader(o.header)
+		if !validRequestID(id) {
+			id = o.generator()
+		}
+		c.Set(requestIDKey, id)
+		if o.inBody {
+			c.Set(requestIDInBodyKey, true)
+		}
+		c.Request = c.Request.WithContext(ContextWithRequestID(c.Request.Context(), id))
+		c.Header(o.header, id)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受长度不超过128的字母、数字和 -_.: 防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.' || ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// responseRequestID 开启了 WithRequestIDInResponse 时返回请求id
+func responseRequestID(c *gin.Context) string {
+	if c.GetBool(requestIDInBodyKey) {
+		return GetRequestID(c)
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"", false},
+		{"abc-123_DEF.4:5", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"a b", false},
+		{"id\nlevel=error", false},
+		{`id"}`, false},
+		{"中文", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.valid {
+			t.Errorf("validRequestID(%q) = %v", tt.id, got)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestID(WithRequestIDGenerator(func() string { return "generated" }), WithRequestIDInResponse()))
+	engine.GET("/", func(c *gin.Context) {
+		if GetRequestID(c) != RequestIDFromContext(c.Request.Context()) {
+			t.Error("request id in gin context and request ctx differ")
+		}
+		NewGinActionImpl(c).Success(RequestIDFromContext(c))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"generated", "", "generated"},
+		{"from header", "client-id-1", "client-id-1"},
+		{"invalid header", "bad\nid", "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w, res := serve(engine, req)
+			if got := w.Header().Get(RequestIDHeader); got != tt.want {
+				t.Errorf("header = %q", got)
+			}
+			if res.RequestID != tt.want || res.Result != tt.want {
+				t.Errorf("response = %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs, panics bytes.Buffer
+	engine := gin.New()
+	engine.Use(RequestID(), Recovery(WithRecoveryOutput(&panics), WithStackToStderr(false)))
+	engine.GET("/log", func(c *gin.Context) {
+		l := (&Logger{logger: newJSONLogger(&logs)}).withArgs(c)
+		l.AddInfoLog(map[string]interface{}{"msg": "gin"})
+		l.WithContext(c.Request.Context()).AddInfoLog(map[string]interface{}{"msg": "ctx"})
+		c.Status(http.StatusNoContent)
+	})
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/log", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	serve(engine, req)
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		entry := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry["request_id"] != "req-1" {
+			t.Errorf("log = %s", line)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+	serve(engine, req)
+	entry := map[string]any{}
+	if err := json.Unmarshal(panics.Bytes(), &entry); err != nil || entry["request_id"] != "req-2" {
+		t.Errorf("recovery log = %s", panics.String())
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"reflect"
+	"testing"
+)
+
+type orderItem struct {
+	Price int    `json:"price" binding:"gt=0"`
+	SKU   string `form:"sku" binding:"required"`
+	Note  string `binding:"max=3"`
+}
+
+type Audit struct {
+	Operator string `json:"operator" binding:"required"`
+}
+
+type orderReq struct {
+	Audit
+	Items   []orderItem            `json:"items" binding:"dive"`
+	Address *struct{ City string } `json:"address"`
+	Meta    map[string]orderItem   `json:"meta" binding:"dive"`
+	Matrix  [][]orderItem          `json:"matrix" binding:"dive,dive"`
+	Ignored string                 `json:"-" form:"ignored_form"`
+	Omit    string                 `json:",omitempty"`
+}
+
+func TestSplitNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      []string
+	}{
+		{"A", []string{"A"}},
+		{"A.B.C", []string{"A", "B", "C"}},
+		{"A.Items[2].Price", []string{"A", "Items[2]", "Price"}},
+		{"A.Meta[a.b].Price", []string{"A", "Meta[a.b]", "Price"}},
+		{"A.Matrix[0][1].SKU", []string{"A", "Matrix[0][1]", "SKU"}},
+	}
+	for _, tt := range tests {
+		if got := splitNamespace(tt.namespace); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitNamespace(%q) = %v; want %v", tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	typ := reflect.TypeOf(&orderReq{})
+	tests := []struct {
+		namespace string
+		path      string
+		name      string
+	}{
+		{"orderReq.Items[2].Price", "items[2].price", "price"},
+		{"orderReq.Items[0].SKU", "items[0].sku", "sku"},
+		{"orderReq.Items[0].Note", "items[0].Note", ""},
+		{"orderReq.Address.City", "address.City", ""},
+		{"orderReq.Meta[a.b].Price", "meta[a.b].price", "price"},
+		{"orderReq.Matrix[0][1].SKU", "matrix[0][1].sku", "sku"},
+		// 没有标签的嵌入结构体展开
+		{"orderReq.Audit.Operator", "operator", "operator"},
+		// json为 - 时使用下一个标签
+		{"orderReq.Ignored", "ignored_form", "ignored_form"},
+		{"orderReq.Omit", "Omit", ""},
+		{"orderReq.Unknown.Field", "Unknown.Field", ""},
+	}
+	r := NewRequest()
+	for _, tt := range tests {
+		path, name := r.fieldPath(typ, tt.namespace)
+		if path != tt.path || name != tt.name {
+			t.Errorf("fieldPath(%q) = %q, %q; want %q, %q", tt.namespace, path, name, tt.path, tt.name)
+		}
+	}
+}
+
+func TestGetValidateErrAllErrors(t *testing.T) {
+	req := &orderReq{
+		Items: []orderItem{{Price: 1, SKU: "a"}, {Price: 0, SKU: "b"}, {Price: 0, Note: "long"}},
+		Meta:  map[string]orderItem{"x": {Price: 1}},
+	}
+	err := NewValidate().ValidateStruct(req)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	single := NewRequest().GetValidateErr(err, req)
+	if single.Message == "" || single.Result != nil {
+		t.Errorf("single = %+v", single)
+	}
+
+	all := NewRequest(WithAllErrors()).GetValidateErr(err, req)
+	fields, ok := all.Result.(map[string][]string)
+	if !ok {
+		t.Fatalf("result = %T", all.Result)
+	}
+	want := []string{"operator", "items[1].price", "items[2].price", "items[2].sku", "items[2].Note", "meta[x].sku"}
+	if len(fields) != len(want) {
+		t.Errorf("fields = %v", fields)
+	}
+	for _, path := range want {
+		if len(fields[path]) != 1 {
+			t.Errorf("%s = %v", path, fields[path])
+		}
+	}
+	if all.Message != single.Message {
+		t.Errorf("message = %q; want the first error %q", all.Message, single.Message)
+	}
+}
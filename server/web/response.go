@@ -10,6 +10,8 @@ type Response struct {
 	Code    int    `json:"code" `
 	Result  any    `json:"result"`
 	Message string `json:"message" `
+	// RequestID 请求id 使用 RequestID 中间件并开启 WithRequestIDInResponse 时返回
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // NewResponse 创建返回数据
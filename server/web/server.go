@@ -0,0 +1,239 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lshaofan/cb-framework/server"
+	"github.com/sirupsen/logrus"
+)
+
+type HTTPServerOption func(*HTTPServer)
+
+// WithAddr 设置监听地址 默认为 :8080 使用 unix:/path/to.sock 监听unix socket
+func WithAddr(addr string) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.addr = addr
+	}
+}
+
+// WithListenerName 优先使用systemd socket激活传入的名称为name的监听 见 server.SystemdEnvironment
+func WithListenerName(name string) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.listenerName = name
+	}
+}
+
+// WithTLS 使用证书文件开启TLS
+func WithTLS(certFile, keyFile string) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.certFile, s.keyFile = certFile, keyFile
+	}
+}
+
+// WithTLSConfig 使用自定义的TLS配置开启TLS 同时设置了 WithTLS 时证书会添加到配置中
+func WithTLSConfig(config *tls.Config) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.tlsConfig = config
+	}
+}
+
+// WithDrainTimeout 设置停止时等待正在处理的请求结束的时间 超时后强制关闭连接 默认30秒
+func WithDrainTimeout(timeout time.Duration) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.drainTimeout = timeout
+	}
+}
+
+// WithHTTPServer 修改底层的 http.Server 例如设置读写超时
+func WithHTTPServer(fn func(*http.Server)) HTTPServerOption {
+	return func(s *HTTPServer) {
+		fn(s.server)
+	}
+}
+
+// WithEngine 使用自定义的gin引擎 不会添加默认的中间件
+func WithEngine(engine *gin.Engine) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.engine = engine
+	}
+}
+
+// WithServerLogger 设置服务启动和停止的日志 默认使用logrus的标准日志
+func WithServerLogger(l logrus.FieldLogger) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.logger = l
+	}
+}
+
+// HTTPServer 运行gin引擎的http服务 实现 server.Service 可以直接使用 server.Run 运行
+type HTTPServer struct {
+	addr         string
+	listenerName string
+	certFile     string
+	keyFile      string
+	tlsConfig    *tls.Config
+	drainTimeout time.Duration
+	logger       logrus.FieldLogger
+
+	engine   *gin.Engine
+	server   *http.Server
+	listener net.Listener
+	inflight atomic.Int64
+	fatal    chan error
+}
+
+// NewHTTPServer 创建http服务 默认的gin引擎使用 GinException 和 GinLoggerFormatter 格式的日志中间件
+func NewHTTPServer(opts ...HTTPServerOption) *HTTPServer {
+	s := &HTTPServer{
+		addr:         ":8080",
+		drainTimeout: 30 * time.Second,
+		logger:       logrus.StandardLogger(),
+		server:       &http.Server{ReadHeaderTimeout: 10 * time.Second},
+		fatal:        make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.engine == nil {
+		s.engine = gin.New()
+		s.engine.Use(gin.LoggerWithFormatter(GinLoggerFormatter), GinException())
+	}
+	s.server.Handler = http.HandlerFunc(s.serveHTTP)
+	return s
+}
+
+// Engine 获取gin引擎 用于注册路由和中间件
+func (s *HTTPServer) Engine() *gin.Engine {
+	return s.engine
+}
+
+// InFlight 正在处理的请求数量
+func (s *HTTPServer) InFlight() int64 {
+	return s.inflight.Load()
+}
+
+// Addr 实际监听的地址 Init 之后可用
+func (s *HTTPServer) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Name 实现 server.Namer
+func (s *HTTPServer) Name() string {
+	return "http:" + s.addr
+}
+
+// Init 实现 server.Service 创建监听 平滑升级时复用旧进程的监听
+func (s *HTTPServer) Init(env server.Environment) error {
+	ln, err := s.listen(env)
+	if err != nil {
+		return err
+	}
+	if s.certFile != "" || s.tlsConfig != nil {
+		config := s.tlsConfig
+		if config == nil {
+			config = &tls.Config{}
+		} else {
+			config = config.Clone()
+		}
+		if s.certFile != "" {
+			cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+			if err != nil {
+				_ = ln.Close()
+				return err
+			}
+			config.Certificates = append(config.Certificates, cert)
+		}
+		if len(config.NextProtos) == 0 {
+			config.NextProtos = []string{"h2", "http/1.1"}
+		}
+		s.server.TLSConfig = config
+		ln = tls.NewListener(ln, config)
+	}
+	s.listener = ln
+	return nil
+}
+
+func (s *HTTPServer) listen(env server.Environment) (net.Listener, error) {
+	if sd, ok := env.(server.SystemdEnvironment); ok && s.listenerName != "" {
+		if ln, ok := sd.Listener(s.listenerName); ok {
+			return ln, nil
+		}
+	}
+	network, address := "tcp", s.addr
+	if strings.HasPrefix(s.addr, "unix:") {
+		network, address = "unix", strings.TrimPrefix(s.addr, "unix:")
+		removeStaleSocket(address)
+	}
+	return server.Listen(network, address)
+}
+
+// removeStaleSocket 删除上次进程异常退出时遗留的socket文件 socket仍在使用时不删除
+func removeStaleSocket(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// Start 实现 server.Service 在后台开始处理请求
+func (s *HTTPServer) Start() error {
+	if s.listener == nil {
+		return errors.New("http服务未初始化")
+	}
+	s.logger.WithField("addr", s.listener.Addr().String()).Info("http: 开始监听")
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.fatal <- err
+		}
+	}()
+	return nil
+}
+
+// Fatal 实现 server.Fataler 监听异常退出时通知
+func (s *HTTPServer) Fatal() <-chan error {
+	return s.fatal
+}
+
+// Stop 实现 server.Service 停止接收新的请求 等待正在处理的请求结束
+func (s *HTTPServer) Stop() error {
+	return s.StopContext(context.Background())
+}
+
+// StopContext 实现 server.ContextStopper 最多等待 WithDrainTimeout 设置的时间或ctx结束 之后强制关闭连接
+func (s *HTTPServer) StopContext(ctx context.Context) error {
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.logger.WithError(err).WithField("inflight", s.InFlight()).Warn("http: 等待请求结束超时 强制关闭连接")
+		return errors.Join(err, s.server.Close())
+	}
+	return nil
+}
+
+// serveHTTP 记录正在处理的请求数量
+func (s *HTTPServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+	s.engine.ServeHTTP(w, r)
+}
@@ -0,0 +1,214 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type testEnvironment map[string]net.Listener
+
+func (e testEnvironment) IsWindowsService() bool { return false }
+func (e testEnvironment) IsSystemd() bool        { return true }
+
+func (e testEnvironment) Listeners() []net.Listener {
+	var listeners []net.Listener
+	for _, ln := range e {
+		listeners = append(listeners, ln)
+	}
+	return listeners
+}
+
+func (e testEnvironment) Listener(name string) (net.Listener, bool) {
+	ln, ok := e[name]
+	return ln, ok
+}
+
+func newTestHTTPServer(opts ...HTTPServerOption) *HTTPServer {
+	gin.SetMode(gin.TestMode)
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	s := NewHTTPServer(append([]HTTPServerOption{WithAddr("127.0.0.1:0"), WithEngine(gin.New()), WithServerLogger(l)}, opts...)...)
+	s.Engine().GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return s
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestHTTPServerStartStop(t *testing.T) {
+	s := newTestHTTPServer()
+	if err := s.Start(); err == nil {
+		t.Fatal("Start before Init succeeded")
+	}
+	if s.Addr() != nil {
+		t.Errorf("addr before Init = %v", s.Addr())
+	}
+	if err := s.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.Addr().String() + "/ping"
+	if body := get(t, http.DefaultClient, url); body != "pong" {
+		t.Errorf("body = %q", body)
+	}
+
+	if err := s.StopContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts requests after stop")
+	}
+	select {
+	case err := <-s.Fatal():
+		t.Errorf("fatal after stop: %v", err)
+	default:
+	}
+}
+
+func TestHTTPServerDrainTimeout(t *testing.T) {
+	s := newTestHTTPServer(WithDrainTimeout(50 * time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	s.Engine().GET("/slow", func(c *gin.Context) {
+		<-release
+	})
+	if err := s.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if resp, err := http.Get("http://" + s.Addr().String() + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for deadline := time.Now().Add(time.Second); s.InFlight() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("request did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	err := s.StopContext(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stop took %v", elapsed)
+	}
+}
+
+func TestHTTPServerUnixSocket(t *testing.T) {
+	// unix socket路径有长度限制 不使用 t.TempDir
+	dir, err := os.MkdirTemp("", "cb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "http.sock")
+	// 遗留的socket文件会被删除
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestHTTPServer(WithAddr("unix:" + path))
+	if err := s.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	if body := get(t, client, "http://unix/ping"); body != "pong" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestHTTPServerListenerName(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestHTTPServer(WithAddr("127.0.0.1:1"), WithListenerName("http"))
+	if err := s.Init(testEnvironment{"http": ln}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Addr().String() != ln.Addr().String() {
+		t.Fatalf("addr = %v; want inherited %v", s.Addr(), ln.Addr())
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if body := get(t, http.DefaultClient, "http://"+ln.Addr().String()+"/ping"); body != "pong" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// windowsEnvironment 没有实现 server.SystemdEnvironment 的环境
+type windowsEnvironment struct{}
+
+func (windowsEnvironment) IsWindowsService() bool { return true }
+
+func TestHTTPServerListenerNameFallback(t *testing.T) {
+	s := newTestHTTPServer(WithListenerName("http"))
+	if err := s.Init(windowsEnvironment{}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.listener.Close()
+	if host, _, _ := net.SplitHostPort(s.Addr().String()); host != "127.0.0.1" {
+		t.Errorf("addr = %v", s.Addr())
+	}
+}
+
+func TestHTTPServerFatal(t *testing.T) {
+	s := newTestHTTPServer()
+	if err := s.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// 监听异常关闭时通过 Fatal 通知
+	_ = s.listener.Close()
+	select {
+	case err := <-s.Fatal():
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("fatal = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listen error was not reported")
+	}
+}
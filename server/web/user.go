@@ -0,0 +1,16 @@
+package web
+
+import "github.com/gin-gonic/gin"
+
+// userIDKey gin上下文中保存当前用户id的key
+const userIDKey = "web.userID"
+
+// SetUserID 保存当前请求的用户id 认证中间件在认证成功后调用 访问日志等会记录此id
+func SetUserID(c *gin.Context, id string) {
+	c.Set(userIDKey, id)
+}
+
+// GetUserID 获取当前请求的用户id 未认证时返回空
+func GetUserID(c *gin.Context) string {
+	return c.GetString(userIDKey)
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidation 注册自定义校验规则 tag 为binding标签中使用的规则名
+// templates 为 语言->错误信息模板 模板中 {0} 为字段名 {1} 为规则参数 例如 {"zh": "{0}必须是合法的手机号", "en": "{0} must be a valid mobile number"}
+func (v *Validate) RegisterValidation(tag string, fn validator.Func, templates map[string]string) error {
+	if err := v.validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	return v.RegisterTranslation(tag, templates)
+}
+
+// RegisterCrossFieldValidation 注册跨字段的校验规则 规则参数为同一结构体中另一个字段的名称
+// 例如 binding:"after=StartAt" fn 的参数为当前字段和参数指定的字段 模板中 {1} 为另一个字段的名称
+func (v *Validate) RegisterCrossFieldValidation(tag string, fn func(field, other reflect.Value) bool, templates map[string]string) error {
+	return v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		other, _, _, ok := fl.GetStructFieldOKAdvanced2(fl.Parent(), fl.Param())
+		if !ok {
+			return false
+		}
+		return fn(fl.Field(), other)
+	}, templates)
+}
+
+// RegisterStructValidation 注册结构体级别的校验 types 为需要校验的结构体
+// fn 中使用 sl.ReportError 报告错误 报告时使用的tag需要使用 RegisterTranslation 注册错误信息模板
+func (v *Validate) RegisterStructValidation(fn validator.StructLevelFunc, types ...any) {
+	v.validate.RegisterStructValidation(fn, types...)
+}
+
+// RegisterTranslation 注册规则在各语言下的错误信息模板 语言需要先使用 RegisterTranslator 注册
+func (v *Validate) RegisterTranslation(tag string, templates map[string]string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for locale, template := range templates {
+		trans, ok := v.locales[locale]
+		if !ok {
+			return fmt.Errorf("校验规则 %s 的语言 %s 未注册", tag, locale)
+		}
+		template := template
+		err := v.validate.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+			return ut.Add(tag, template, true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, err := ut.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return t
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterScene 注册校验场景 场景下只校验fields中的字段 嵌套字段使用 Address.City 的形式
+// 例如创建时校验所有字段 更新时只校验 ID 和 Name: RegisterScene(&User{}, "update", "ID", "Name")
+func (v *Validate) RegisterScene(obj any, scene string, fields ...string) {
+	t := indirect(reflect.TypeOf(obj))
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.scenes == nil {
+		v.scenes = make(map[reflect.Type]map[string][]string)
+	}
+	if v.scenes[t] == nil {
+		v.scenes[t] = make(map[string][]string)
+	}
+	v.scenes[t][scene] = fields
+}
+
+// ValidateScene 使用场景校验结构体 场景未注册时校验所有字段
+func (v *Validate) ValidateScene(obj any, scene string) error {
+	if fields, ok := v.sceneFields(obj, scene); ok {
+		return v.validate.StructPartial(obj, fields...)
+	}
+	return v.validate.Struct(obj)
+}
+
+// WithScene 在 fn 中绑定obj时使用场景校验 fn 中通过gin的任意绑定方法绑定obj即可
+func (v *Validate) WithScene(obj any, scene string, fn func() error) error {
+	key := reflect.ValueOf(obj).Pointer()
+	v.binding.scenes.Store(key, scene)
+	defer v.binding.scenes.Delete(key)
+	return fn()
+}
+
+func (v *Validate) sceneFields(obj any, scene string) ([]string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	fields, ok := v.scenes[indirect(reflect.TypeOf(obj))][scene]
+	return fields, ok
+}
+
+// sceneValidator 替换gin的校验器 正在使用 WithScene 绑定的对象按场景校验
+type sceneValidator struct {
+	binding.StructValidator
+	validate *Validate
+	scenes   sync.Map
+}
+
+// ValidateStruct 实现 binding.StructValidator
+func (s *sceneValidator) ValidateStruct(obj any) error {
+	if obj != nil && reflect.TypeOf(obj).Kind() == reflect.Pointer {
+		if scene, ok := s.scenes.Load(reflect.ValueOf(obj).Pointer()); ok {
+			return s.validate.ValidateScene(obj, scene.(string))
+		}
+	}
+	return s.StructValidator.ValidateStruct(obj)
+}
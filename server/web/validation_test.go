@@ -0,0 +1,151 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var mobilePattern = regexp.MustCompile(`^1\d{10}$`)
+
+type signupForm struct {
+	Mobile   string `json:"mobile" binding:"required,test_mobile"`
+	Start    int    `json:"start"`
+	End      int    `json:"end" binding:"test_after=Start"`
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+func init() {
+	v := NewValidate()
+	_ = v.RegisterValidation("test_mobile", func(fl validator.FieldLevel) bool {
+		return mobilePattern.MatchString(fl.Field().String())
+	}, map[string]string{"zh": "{0}必须是合法的手机号", "en": "{0} must be a valid mobile number"})
+	_ = v.RegisterCrossFieldValidation("test_after", func(field, other reflect.Value) bool {
+		return field.Int() > other.Int()
+	}, map[string]string{"zh": "{0}必须大于{1}", "en": "{0} must be greater than {1}"})
+	_ = v.RegisterTranslation("test_confirm", map[string]string{"zh": "两次输入的密码不一致", "en": "passwords do not match"})
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		f := sl.Current().Interface().(signupForm)
+		if f.Password != f.Confirm {
+			sl.ReportError(f.Confirm, "Confirm", "Confirm", "test_confirm", "")
+		}
+	}, signupForm{})
+}
+
+func validateErr(t *testing.T, obj any, opts ...RequestOption) *ErrorModel {
+	t.Helper()
+	err := NewValidate().ValidateStruct(obj)
+	if err == nil {
+		return nil
+	}
+	return NewRequest(opts...).GetValidateErr(err, obj)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	valid := &signupForm{Mobile: "13800000000", Start: 1, End: 2}
+	if err := validateErr(t, valid); err != nil {
+		t.Fatalf("valid form: %v", err)
+	}
+
+	form := &signupForm{Mobile: "123", Start: 1, End: 2}
+	if err := validateErr(t, form); err == nil || err.Message != "mobile必须是合法的手机号" {
+		t.Errorf("zh = %v", err)
+	}
+	if err := validateErr(t, form, WithLocale(LocaleEn)); err == nil || err.Message != "mobile must be a valid mobile number" {
+		t.Errorf("en = %v", err)
+	}
+	// 没有翻译的语言使用默认语言
+	if err := validateErr(t, form, WithLocale("fr")); err == nil || err.Message != "mobile必须是合法的手机号" {
+		t.Errorf("fallback = %v", err)
+	}
+	if err := NewValidate().RegisterTranslation("test_unknown", map[string]string{"fr": "{0}"}); err == nil {
+		t.Error("unregistered locale accepted")
+	}
+}
+
+func TestCrossFieldValidation(t *testing.T) {
+	form := &signupForm{Mobile: "13800000000", Start: 5, End: 3}
+	if err := validateErr(t, form, WithLocale(LocaleEn)); err == nil || err.Message != "end must be greater than Start" {
+		t.Errorf("cross field = %v", err)
+	}
+}
+
+func TestStructValidation(t *testing.T) {
+	form := &signupForm{Mobile: "13800000000", Start: 1, End: 2, Password: "a", Confirm: "b"}
+	err := validateErr(t, form, WithAllErrors())
+	if err == nil || !strings.Contains(err.Message, "两次输入的密码不一致") {
+		t.Fatalf("struct level = %v", err)
+	}
+	if fields, _ := err.Result.(map[string][]string); len(fields["confirm"]) != 1 {
+		t.Errorf("fields = %v", err.Result)
+	}
+}
+
+type sceneUser struct {
+	ID   int    `json:"id" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+func TestWithScene(t *testing.T) {
+	v := NewValidate()
+	if _, ok := binding.Validator.(*sceneValidator); !ok {
+		t.Fatalf("binding.Validator = %T", binding.Validator)
+	}
+	v.RegisterScene(&sceneUser{}, "update", "ID")
+
+	gin.SetMode(gin.TestMode)
+	bind := func(scene string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":1}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		user := &sceneUser{}
+		if scene == "" {
+			return c.ShouldBindJSON(user)
+		}
+		return v.WithScene(user, scene, func() error {
+			return c.ShouldBindJSON(user)
+		})
+	}
+
+	if err := bind("update"); err != nil {
+		t.Errorf("update scene: %v", err)
+	}
+	var errs validator.ValidationErrors
+	if err := bind(""); !errors.As(err, &errs) || errs[0].Field() != "Name" {
+		t.Errorf("without scene: %v", err)
+	}
+	// 未注册的场景校验所有字段
+	if err := bind("create"); err == nil {
+		t.Error("unknown scene skipped validation")
+	}
+	// WithScene 结束后恢复正常校验
+	if err := bind(""); err == nil {
+		t.Error("scene leaked after WithScene")
+	}
+}
+
+func TestSceneBinder(t *testing.T) {
+	NewValidate().RegisterScene(&sceneUser{}, "update", "ID")
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":1}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	var action Action = NewGinActionImpl(c)
+	binder, ok := action.(SceneBinder)
+	if !ok {
+		t.Fatal("GinActionImpl does not implement SceneBinder")
+	}
+	if err := binder.BindParamWithScene(&sceneUser{}, "update"); err != nil {
+		t.Errorf("update scene: %v", err)
+	}
+}